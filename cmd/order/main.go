@@ -1,18 +1,22 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/bxiit/order-service-pet-store/config"
 	"github.com/bxiit/order-service-pet-store/internal/app"
-	grpcapp "github.com/bxiit/order-service-pet-store/internal/app/grpc"
+	"github.com/bxiit/order-service-pet-store/internal/jsonLog"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
 	"github.com/bxiit/order-service-pet-store/internal/sl"
-	orderv1 "github.com/bxiit/protos/gen/go/order"
-	ssov1 "github.com/bxiit/protos/gen/go/sso"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/grpclog"
+	"gopkg.in/yaml.v3"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
@@ -23,76 +27,198 @@ const (
 )
 
 func main() {
-	cfg := config.LoadConfig()
-	log := setupLogger(cfg.Env)
-	application := app.New(log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTtl)
+	cfg, configPath, printConfig, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if printConfig {
+		out, err := yaml.Marshal(cfg.Masked())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
+	level := new(slog.LevelVar)
+	log, logCloser, err := setupLogger(cfg.Env, cfg.Log, level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to set up logging:", err)
+		os.Exit(1)
+	}
+	runtimeSettings := settings.New(cfg, level)
+	code := run(log, cfg, configPath, runtimeSettings)
+	if logCloser != nil {
+		_ = logCloser.Close()
+	}
+	os.Exit(code)
+}
+
+// loadConfig parses the command line and loads the config from the file
+// named by --config, falling back to $CONFIG_PATH.
+func loadConfig(fs *flag.FlagSet, args []string) (cfg *config.Config, configPath string, printConfig bool, err error) {
+	fs.StringVar(&configPath, "config", os.Getenv("CONFIG_PATH"), "path to the YAML config file (optional, defaults to $CONFIG_PATH)")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective config with secrets masked and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), config.Usage())
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", false, err
+	}
 
-	ConnectToSsoService()
-	ConnectToOrderService()
+	cfg, err = config.Load(configPath)
+	return cfg, configPath, printConfig, err
+}
+
+// run starts the application, serves until a termination signal or a
+// component failure, shuts down and returns the process exit code.
+func run(log *slog.Logger, cfg *config.Config, configPath string, runtimeSettings *settings.Settings) int {
+	application, err := app.New(log, cfg, runtimeSettings)
+	if err != nil {
+		log.Error("failed to build application", sl.Err(err))
+		return 1
+	}
 
-	go func() {
-		application.GRPCServer.MustRun()
-	}()
+	startCtx, cancelStart := context.WithTimeout(context.Background(), cfg.StartTimeout)
+	err = application.Start(startCtx)
+	cancelStart()
+	if err != nil {
+		log.Error("failed to start application", sl.Err(err))
+		return 1
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
-	<-stop
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	code := 0
+	for running := true; running; {
+		select {
+		case <-reload:
+			if next, err := reloadConfig(log, cfg, configPath, runtimeSettings); err != nil {
+				log.Error("config reload rejected", sl.Err(err))
+			} else {
+				cfg = next
+			}
+		case err := <-application.Errors():
+			log.Error("component failed", sl.Err(err))
+			code = 1
+			running = false
+		case <-stop:
+			running = false
+		}
+	}
+
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelStop()
+
+	if err := application.Stop(stopCtx); err != nil {
+		log.Error("failed to stop application gracefully", sl.Err(err))
+		return 1
+	}
+
+	log.Info("Order service gracefully stopped")
 
-	application.GRPCServer.Stop()
-	log.Info("Catalogue service gracefully stopped")
+	return code
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+// reloadConfig re-reads the config file and applies its reloadable fields.
+// The whole reload is rejected when a field that needs a restart has changed.
+func reloadConfig(log *slog.Logger, current *config.Config, path string, runtimeSettings *settings.Settings) (*config.Config, error) {
+	next, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := config.Diff(current, next)
+
+	var fixed []string
+	for _, c := range changes {
+		if !config.Reloadable(c.Path) {
+			fixed = append(fixed, c.Path)
+		}
+	}
+	if len(fixed) > 0 {
+		return nil, fmt.Errorf("fields require a restart: %s", strings.Join(fixed, ", "))
+	}
+
+	runtimeSettings.Apply(next)
 
-	switch env {
-	case envLocal:
-		log = setupPrettySlog()
-	case envDev:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
-	case envProd:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+	// Logged at warn so the diff stays visible whatever the new level is.
+	for _, c := range changes {
+		log.Warn("config changed", slog.String("field", c.Path), slog.String("old", c.Old), slog.String("new", c.New))
 	}
+	log.Warn("config reloaded", slog.Int("changes", len(changes)))
 
-	return log
+	return next, nil
 }
 
-func setupPrettySlog() *slog.Logger {
-	opts := sl.PrettyHandlerOptions{
-		SlogOpts: &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		},
+// setupLogger builds the logger described by cfg. The returned closer
+// releases the log file and is nil when logging to a standard stream.
+func setupLogger(env string, cfg config.LogConfig, level *slog.LevelVar) (*slog.Logger, io.Closer, error) {
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+
+	switch cfg.Output {
+	case "stderr":
+		out = os.Stderr
+	case "file":
+		file, err := sl.NewRotatingFile(cfg.File.Path, int64(cfg.File.MaxSizeMB)<<20, cfg.File.MaxAge, cfg.File.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
 	}
 
-	handler := opts.NewPrettyHandler(os.Stdout)
+	format := cfg.Format
+	if format == "" {
+		format = "json"
+		if env == envLocal {
+			format = "pretty"
+		}
+	}
 
-	return slog.New(handler)
-}
+	var handler slog.Handler
+	switch format {
+	case "pretty":
+		handler = setupPrettySlog(out, level)
+	case "text":
+		handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
+	case "jsonlog":
+		jsonHandler := jsonLog.NewHandler(out, level)
+		// Route the gRPC library's warnings and errors through the same handler.
+		grpclog.SetLoggerV2(jsonHandler.GRPCLogger())
+		handler = jsonHandler
+	default:
+		handler = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})
+	}
 
-func failOnError(err error, msg string) {
-	if err != nil {
-		log.Panicf("%s: %s", msg, err)
+	if cfg.Sampling.Initial > 0 {
+		handler = sl.NewSamplingHandler(handler, cfg.Sampling.Initial, cfg.Sampling.Thereafter, cfg.Sampling.Tick)
 	}
+
+	return slog.New(handler), closer, nil
 }
 
-func ConnectToSsoService() {
-	conn, err := grpc.NewClient("0.0.0.0:44044", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("failed to connect to auth service: %v", err)
+func setupPrettySlog(out io.Writer, level *slog.LevelVar) slog.Handler {
+	opts := sl.PrettyHandlerOptions{
+		SlogOpts: &slog.HandlerOptions{
+			Level: level,
+		},
 	}
-	grpcapp.AuthServiceClient = ssov1.NewAuthClient(conn)
-	grpcapp.UserInfoServiceClient = ssov1.NewUserInfoClient(conn)
+
+	return opts.NewPrettyHandler(out)
 }
 
-func ConnectToOrderService() {
-	conn, err := grpc.NewClient("0.0.0.0:44046", grpc.WithTransportCredentials(insecure.NewCredentials()))
+func failOnError(err error, msg string) {
 	if err != nil {
-		log.Fatalf("failed to connect to order service: %v", err)
+		log.Panicf("%s: %s", msg, err)
 	}
-	grpcapp.OrderServiceClient = orderv1.NewOrderServiceClient(conn)
 }
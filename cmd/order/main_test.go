@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"github.com/bxiit/order-service-pet-store/config"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("storage_driver: memory\n"+body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfig checks the precedence of the config sources: defaults, then
+// the file from --config or $CONFIG_PATH, then ORDER_* variables.
+func TestLoadConfig(t *testing.T) {
+	fromEnv := writeConfig(t, "grpc:\n  port: 1000\n")
+	fromFlag := writeConfig(t, `
+grpc:
+  port: 2000
+http:
+  port: 8080
+rate_limits:
+  default:
+    rps: 20
+    burst: 40
+  methods:
+    CreateOrder:
+      rps: 2
+      burst: 5
+`)
+	t.Setenv("CONFIG_PATH", fromEnv)
+
+	tests := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		mutate func(c *config.Config)
+	}{
+		{"config path", nil, nil, func(c *config.Config) {
+			c.GRPC.Port = 1000
+		}},
+		{"flag over config path", []string{"--config", fromFlag}, nil, func(c *config.Config) {
+			c.GRPC.Port = 2000
+			c.HTTP.Port = 8080
+			c.RateLimits.Default = config.RateLimit{RPS: 20, Burst: 40}
+			c.RateLimits.Methods = config.RateLimits{"CreateOrder": {RPS: 2, Burst: 5}}
+		}},
+		{"env over file", []string{"--config", fromFlag}, map[string]string{
+			"ORDER_HTTP_PORT":           "9090",
+			"ORDER_RATE_LIMITS_METHODS": "ListOrders:0.5/3, GetOrder:10/20",
+		}, func(c *config.Config) {
+			c.GRPC.Port = 2000
+			c.HTTP.Port = 9090
+			c.RateLimits.Default = config.RateLimit{RPS: 20, Burst: 40}
+			c.RateLimits.Methods = config.RateLimits{"ListOrders": {RPS: 0.5, Burst: 3}, "GetOrder": {RPS: 10, Burst: 20}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			fs := flag.NewFlagSet("order", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			got, _, _, err := loadConfig(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defaults, err := config.Load(writeConfig(t, ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(defaults)
+			if !reflect.DeepEqual(got, defaults) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, defaults)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_BadRateLimits(t *testing.T) {
+	t.Setenv("ORDER_RATE_LIMITS_METHODS", "CreateOrder:2")
+
+	fs := flag.NewFlagSet("order", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, _, _, err := loadConfig(fs, []string{"--config", writeConfig(t, "")}); err == nil {
+		t.Error("no error")
+	}
+}
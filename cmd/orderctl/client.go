@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/creds"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"strings"
+	"time"
+)
+
+// tokenEnv holds the SSO token when no token file is given.
+const tokenEnv = "ORDERCTL_TOKEN"
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// clientFlags are the connection and output flags shared by the commands
+// talking to a running order service.
+type clientFlags struct {
+	configPath string
+	addr       string
+	tokenFile  string
+	output     string
+	timeout    time.Duration
+	tls        config.TLSConfig
+}
+
+func newClientFlags(fs *flag.FlagSet) *clientFlags {
+	f := &clientFlags{}
+	fs.StringVar(&f.configPath, "config", os.Getenv("CONFIG_PATH"), "path to the service config; gives the port and TLS settings")
+	fs.StringVar(&f.addr, "addr", "", "service address (default localhost and the grpc.port of the config)")
+	fs.StringVar(&f.tokenFile, "token-file", "", "file holding the SSO token (default $"+tokenEnv+")")
+	fs.StringVar(&f.output, "o", outputTable, "output format, table or json")
+	fs.DurationVar(&f.timeout, "timeout", 10*time.Second, "deadline of each call")
+	fs.BoolVar(&f.tls.Enabled, "tls", false, "use TLS (default on when the config enables it)")
+	fs.StringVar(&f.tls.CAFile, "ca", "", "PEM CA bundle to verify the server with (default the system roots)")
+	fs.StringVar(&f.tls.CertFile, "cert", "", "PEM client certificate, for a server requiring mTLS")
+	fs.StringVar(&f.tls.KeyFile, "key", "", "PEM private key of -cert")
+	fs.StringVar(&f.tls.ServerName, "server-name", "", "name expected in the server certificate (default the host of the address)")
+	return f
+}
+
+func (f *clientFlags) validate() error {
+	if f.output != outputTable && f.output != outputJSON {
+		return usagef("-o must be %s or %s", outputTable, outputJSON)
+	}
+	if f.configPath == "" && f.addr == "" {
+		return usagef("either -config or -addr is required")
+	}
+	if (f.tls.CertFile == "") != (f.tls.KeyFile == "") {
+		return usagef("-cert and -key go together")
+	}
+	return nil
+}
+
+// dial connects to the order service, plaintext unless -tls is given or the
+// config enables TLS. The client presents its own certificate, if any, never
+// the server's. Each RPC on the connection is bounded by -timeout.
+func (f *clientFlags) dial() (*grpc.ClientConn, error) {
+	addr := f.addr
+	tlsCfg := f.tls
+
+	if f.configPath != "" {
+		cfg, err := config.Load(f.configPath)
+		if err != nil {
+			return nil, err
+		}
+		if addr == "" {
+			addr = fmt.Sprintf("localhost:%d", cfg.GRPC.Port)
+		}
+		tlsCfg.Enabled = tlsCfg.Enabled || cfg.GRPC.TLS.Enabled
+	}
+
+	dialCreds, err := creds.Client(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(dialCreds),
+		grpc.WithChainUnaryInterceptor(f.deadline),
+	)
+}
+
+// deadline gives every unary RPC its own -timeout, so a command making many
+// calls, like list -all, is not cut short by a shared deadline.
+func (f *clientFlags) deadline(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// callContext attaches the token, if any, to the calls made with ctx.
+func (f *clientFlags) callContext(ctx context.Context) (context.Context, error) {
+	token, err := f.token()
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", token)
+	}
+
+	return ctx, nil
+}
+
+// token reads the SSO token from -token-file or the environment. Tokens are
+// deliberately not taken as a flag, where they would show up in ps.
+func (f *clientFlags) token() (string, error) {
+	if f.tokenFile == "" {
+		return os.Getenv(tokenEnv), nil
+	}
+
+	b, err := os.ReadFile(f.tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// printJSON writes a message as indented JSON using the proto field names.
+func printJSON(m proto.Message) error {
+	b, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", UseProtoNames: true, EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// printValue writes a plain value as indented JSON.
+func printValue(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
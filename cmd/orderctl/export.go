@@ -0,0 +1,180 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/export"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("export")
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to the service config, read for storage_path")
+	dsn := fs.String("dsn", "", "database URL, overrides storage_path from the config")
+	output := fs.String("o", "-", `output file, "-" for stdout`)
+	format := fs.String("format", "", "csv or jsonl (default from the output file extension, else csv)")
+	compress := fs.Bool("gzip", false, "gzip the output (implied by a .gz output file)")
+	columnList := fs.String("columns", strings.Join(export.DefaultColumns, ","), "comma-separated columns to export")
+	from := fs.String("from", "", "only orders created at or after this time (2006-01-02 or RFC 3339)")
+	to := fs.String("to", "", "only orders created before this time (2006-01-02 or RFC 3339)")
+	status := fs.String("status", "", "only orders with this status")
+	userId := fs.Int("user", 0, "only orders of this user id")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	filter := models.ExportFilter{Status: *status, UserId: int32(*userId)}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		return usagef("-from: %v", err)
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		return usagef("-to: %v", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return usagef("-to must be after -from")
+	}
+	if filter.Status != "" && filter.Status != models.StatusCreated && filter.Status != models.StatusCancelled {
+		return usagef("-status must be %s or %s", models.StatusCreated, models.StatusCancelled)
+	}
+	if *userId < 0 {
+		return usagef("-user must be positive")
+	}
+
+	columns, err := export.ParseColumns(*columnList)
+	if err != nil {
+		return usagef("-columns: %v", err)
+	}
+
+	name := *output
+	if strings.HasSuffix(name, ".gz") {
+		*compress = true
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	if *format == "" {
+		*format = export.FormatCSV
+		if ext := strings.TrimPrefix(filepath.Ext(name), "."); ext == export.FormatJSONL {
+			*format = ext
+		}
+	}
+	if *format != export.FormatCSV && *format != export.FormatJSONL {
+		return usagef("-format must be %s or %s", export.FormatCSV, export.FormatJSONL)
+	}
+
+	storage, err := openStorage(*configPath, *dsn)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	return writeExport(*output, *compress, func(out io.Writer) (int, error) {
+		w, err := export.New(*format, out, columns)
+		if err != nil {
+			return 0, err
+		}
+
+		n := 0
+		err = storage.ExportOrders(ctx, filter, func(order *dto.OrderDTO) error {
+			n++
+			return w.Write(order)
+		})
+		if err != nil {
+			return n, err
+		}
+
+		return n, w.Close()
+	})
+}
+
+// writeExport runs write against the output file, or stdout for "-", gzipped
+// if asked. A file is written under a temporary name and only renamed into
+// place once the export completed, so a failed export never leaves a
+// truncated file behind.
+func writeExport(output string, compress bool, write func(io.Writer) (int, error)) error {
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if output != "-" {
+		var err error
+		file, err = os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		out = file
+	}
+
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(out)
+		out = zw
+	}
+
+	n, err := write(out)
+	if err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	if file != nil {
+		if err := file.Chmod(0o644); err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(file.Name(), output); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d orders\n", n)
+
+	return nil
+}
+
+// openStorage connects to the database given by dsn or, when empty, by the
+// storage_path of the config at configPath.
+func openStorage(configPath, dsn string) (*data.OrderStorage, error) {
+	if dsn == "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.StorageDriver != "postgres" {
+			return nil, fmt.Errorf("the %s storage driver keeps no database, pass -dsn", cfg.StorageDriver)
+		}
+		dsn = cfg.StoragePath
+	}
+
+	return data.New(dsn)
+}
+
+// parseTime accepts a date, taken as UTC midnight, or an RFC 3339 time.
+// An empty string gives the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want 2006-01-02 or RFC 3339, got %q", s)
+	}
+	return t, nil
+}
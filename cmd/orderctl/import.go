@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runImport(ctx context.Context, args []string) error {
+	fs := newFlagSet("import")
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to the service config, read for storage_path")
+	dsn := fs.String("dsn", "", "database URL, overrides storage_path from the config")
+	input := fs.String("i", "-", `input file, "-" for stdin; a .gz file is decompressed`)
+	format := fs.String("format", "", "csv or jsonl (default from the input file extension, else csv)")
+	dryRun := fs.Bool("dry-run", false, "validate every row without storing anything")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "rows validated and inserted per transaction")
+	reportPath := fs.String("report", "", "write the JSON import report to this file instead of stderr")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *batchSize < 1 || *batchSize > importer.MaxBatchSize {
+		return usagef("-batch-size must be between 1 and %d", importer.MaxBatchSize)
+	}
+
+	name := strings.TrimSuffix(*input, ".gz")
+	if *format == "" {
+		*format = importer.FormatCSV
+		if ext := strings.TrimPrefix(filepath.Ext(name), "."); ext == importer.FormatJSONL {
+			*format = ext
+		}
+	}
+	if *format != importer.FormatCSV && *format != importer.FormatJSONL {
+		return usagef("-format must be %s or %s", importer.FormatCSV, importer.FormatJSONL)
+	}
+
+	var in io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	if strings.HasSuffix(*input, ".gz") {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		in = zr
+	}
+
+	src, err := importer.NewDecoder(*format, in)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage(*configPath, *dsn)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	report, err := importer.New(log, storage, *batchSize).Import(ctx, src, *dryRun)
+	if report != nil {
+		if werr := writeReport(*reportPath, report); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(os.Stderr, "%d rows: %s %d, %d failed\n", report.Rows, verb, report.Imported, report.Failed)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d rows failed", report.Failed)
+	}
+
+	return nil
+}
+
+// writeReport writes the report as indented JSON to path, or to stderr when
+// path is empty.
+func writeReport(path string, report *importer.Report) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if path == "" {
+		_, err = os.Stderr.Write(b)
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o644)
+}
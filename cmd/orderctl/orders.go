@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	orderv1 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/grpc/metadata"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+func runCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("create")
+	client := newClientFlags(fs)
+	userId := fs.Int("user", 0, "id of the ordering user")
+	itemId := fs.Int("item", 0, "id of the ordered item")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := client.validate(); err != nil {
+		return err
+	}
+	if *userId <= 0 || *itemId <= 0 {
+		return usagef("-user and -item are required")
+	}
+
+	return withClient(ctx, client, func(ctx context.Context, c orderv1.OrderServiceClient, _ *orderGrpc.ExtensionClient) error {
+		resp, err := c.CreateOrder(ctx, &orderv1.CreateOrderRequest{
+			Order: &orderv1.Order{UserId: int32(*userId), ItemId: int32(*itemId)},
+		})
+		if err != nil {
+			return err
+		}
+
+		if client.output == outputJSON {
+			return printJSON(resp.GetOrder())
+		}
+		return printOrders(resp.GetOrder())
+	})
+}
+
+func runGet(ctx context.Context, args []string) error {
+	fs := newFlagSet("get")
+	client := newClientFlags(fs)
+	id := fs.Int("id", 0, "order id")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := client.validate(); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return usagef("-id is required")
+	}
+
+	return withClient(ctx, client, func(ctx context.Context, c orderv1.OrderServiceClient, _ *orderGrpc.ExtensionClient) error {
+		resp, err := c.GetOrder(ctx, &orderv1.GetOrderRequest{Id: strconv.Itoa(*id)})
+		if err != nil {
+			return err
+		}
+
+		if client.output == outputJSON {
+			return printJSON(resp.GetOrder())
+		}
+		return printOrders(resp.GetOrder())
+	})
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs := newFlagSet("list")
+	client := newClientFlags(fs)
+	userId := fs.Int("user", 0, "only orders of this user id")
+	status := fs.String("status", "", "only orders with this status")
+	limit := fs.Int("limit", 20, "orders per page")
+	offset := fs.Int("offset", 0, "orders to skip")
+	all := fs.Bool("all", false, "fetch every page starting at -offset")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := client.validate(); err != nil {
+		return err
+	}
+	if *limit < 1 || *offset < 0 || *userId < 0 {
+		return usagef("-limit must be positive, -offset and -user not negative")
+	}
+	if *status != "" && *status != models.StatusCreated && *status != models.StatusCancelled {
+		return usagef("-status must be %s or %s", models.StatusCreated, models.StatusCancelled)
+	}
+
+	return withClient(ctx, client, func(ctx context.Context, c orderv1.OrderServiceClient, _ *orderGrpc.ExtensionClient) error {
+		var orders []*orderv1.Order
+		for page := *offset; ; page += *limit {
+			pairs := []string{
+				orderGrpc.MetadataListLimit, strconv.Itoa(*limit),
+				orderGrpc.MetadataListOffset, strconv.Itoa(page),
+			}
+			if *userId != 0 {
+				pairs = append(pairs, orderGrpc.MetadataListUserId, strconv.Itoa(*userId))
+			}
+			if *status != "" {
+				pairs = append(pairs, orderGrpc.MetadataListStatus, *status)
+			}
+
+			resp, err := c.ListOrders(metadata.AppendToOutgoingContext(ctx, pairs...), &orderv1.ListOrdersRequest{})
+			if err != nil {
+				return err
+			}
+			orders = append(orders, resp.GetOrders()...)
+
+			if !*all || len(resp.GetOrders()) < *limit {
+				break
+			}
+		}
+
+		if client.output == outputJSON {
+			return printJSON(&orderv1.ListOrdersResponse{Orders: orders})
+		}
+		return printOrders(orders...)
+	})
+}
+
+func runCancel(ctx context.Context, args []string) error {
+	fs := newFlagSet("cancel")
+	client := newClientFlags(fs)
+	id := fs.Int("id", 0, "order id")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := client.validate(); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return usagef("-id is required")
+	}
+
+	return withClient(ctx, client, func(ctx context.Context, _ orderv1.OrderServiceClient, ext *orderGrpc.ExtensionClient) error {
+		return cancelOrder(ctx, ext, client.output, *id)
+	})
+}
+
+// runStatus moves an order to another status. Orders can only go from
+// created to cancelled, so that is the only transition offered.
+func runStatus(ctx context.Context, args []string) error {
+	fs := newFlagSet("status")
+	client := newClientFlags(fs)
+	id := fs.Int("id", 0, "order id")
+	to := fs.String("set", "", "new status; only "+models.StatusCancelled+" is supported")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := client.validate(); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return usagef("-id is required")
+	}
+	if *to != models.StatusCancelled {
+		return usagef("-set must be %s, the only status an order can be moved to", models.StatusCancelled)
+	}
+
+	return withClient(ctx, client, func(ctx context.Context, _ orderv1.OrderServiceClient, ext *orderGrpc.ExtensionClient) error {
+		return cancelOrder(ctx, ext, client.output, *id)
+	})
+}
+
+func cancelOrder(ctx context.Context, ext *orderGrpc.ExtensionClient, output string, id int) error {
+	if _, err := ext.CancelOrder(ctx, &orderv1.DeleteOrderRequest{Id: int32(id)}); err != nil {
+		return err
+	}
+
+	if output == outputJSON {
+		return printValue(map[string]interface{}{"id": id, "status": models.StatusCancelled})
+	}
+	fmt.Printf("order %d %s\n", id, models.StatusCancelled)
+
+	return nil
+}
+
+// withClient dials the service and runs call with both clients and a call
+// context carrying the token.
+func withClient(ctx context.Context, flags *clientFlags, call func(context.Context, orderv1.OrderServiceClient, *orderGrpc.ExtensionClient) error) error {
+	conn, err := flags.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, err = flags.callContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return call(ctx, orderv1.NewOrderServiceClient(conn), orderGrpc.NewExtensionClient(conn))
+}
+
+func printOrders(orders ...*orderv1.Order) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tITEM\tITEM NAME\tPRICE")
+	for _, o := range orders {
+		name, price := "", ""
+		if item := o.GetItem(); item != nil {
+			name, price = item.GetName(), strconv.Itoa(int(item.GetPrice()))
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\n", o.GetId(), o.GetUserId(), o.GetItemId(), name, price)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/seed"
+	"os"
+	"time"
+)
+
+func runSeed(ctx context.Context, args []string) error {
+	fs := newFlagSet("seed")
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to the service config, read for storage_path")
+	dsn := fs.String("dsn", "", "database URL, overrides storage_path from the config")
+	schemaOnly := fs.Bool("schema-only", false, "only create the schemas and stub tables, e.g. before running the migrations")
+	reset := fs.Bool("reset", false, "delete every order, item and user first; needs -yes")
+	yes := fs.Bool("yes", false, "confirm -reset")
+	seedValue := fs.Int64("seed", 1, "random seed; the same seed and -end give the same data")
+	users := fs.Int("users", 50, "users to create")
+	items := fs.Int("items", 100, "items to create")
+	orders := fs.Int("orders", 1000, "orders to create")
+	days := fs.Int("days", 90, "spread orders over this many days before -end")
+	end := fs.String("end", "", "latest order time, 2006-01-02 or RFC 3339 (default today, UTC midnight)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *users < 0 || *items < 0 || *orders < 0 || *days < 1 {
+		return usagef("-users, -items and -orders must not be negative, -days must be positive")
+	}
+	if *orders > 0 && (*users == 0 || *items == 0) {
+		return usagef("-orders needs at least one user and one item")
+	}
+	if *reset && !*yes {
+		return usagef("-reset deletes every order, item and user; pass -yes to confirm")
+	}
+	endTime, err := parseTime(*end)
+	if err != nil {
+		return usagef("-end: %v", err)
+	}
+	if endTime.IsZero() {
+		endTime = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if *reset {
+		if err := checkLocal(*configPath, *dsn); err != nil {
+			return err
+		}
+	}
+
+	storage, err := openStorage(*configPath, *dsn)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	seeder := seed.New(storage.DB)
+	if err := seeder.EnsureSchema(ctx); err != nil {
+		return err
+	}
+	if *schemaOnly {
+		fmt.Fprintln(os.Stderr, "schemas and stub tables are in place")
+		return nil
+	}
+
+	if *reset {
+		if err := seeder.Reset(ctx); err != nil {
+			return err
+		}
+	}
+
+	result, err := seeder.Seed(ctx, seed.Options{
+		Seed:   *seedValue,
+		Users:  *users,
+		Items:  *items,
+		Orders: *orders,
+		Days:   *days,
+		End:    endTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "seeded %d users, %d items and %d orders\n", result.Users, result.Items, result.Orders)
+
+	return nil
+}
+
+func runReset(ctx context.Context, args []string) error {
+	fs := newFlagSet("reset")
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to the service config, read for storage_path")
+	dsn := fs.String("dsn", "", "database URL, overrides storage_path from the config")
+	yes := fs.Bool("yes", false, "confirm deleting every order, item and user")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !*yes {
+		return usagef("reset deletes every order, item and user; pass -yes to confirm")
+	}
+	if err := checkLocal(*configPath, *dsn); err != nil {
+		return err
+	}
+
+	storage, err := openStorage(*configPath, *dsn)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	seeder := seed.New(storage.DB)
+	if err := seeder.EnsureSchema(ctx); err != nil {
+		return err
+	}
+	if err := seeder.Reset(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "database reset")
+
+	return nil
+}
+
+// checkLocal refuses to wipe a database taken from a config for any
+// environment but local. An explicit -dsn is trusted as is.
+func checkLocal(configPath, dsn string) error {
+	if dsn != "" {
+		return nil
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Env != "local" {
+		return usagef("refusing to reset the database of the %s environment; pass -dsn to name it explicitly", cfg.Env)
+	}
+
+	return nil
+}
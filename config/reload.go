@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+)
+
+// reloadable lists the config paths (and path prefixes ending in ".") that
+// may change while the service is running.
+var reloadable = []string{
+	"log.level",
+	"auth.",
+	"features.",
+	"rate_limits.",
+}
+
+// Change is a single config field that differs between two configs.
+type Change struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Path, c.Old, c.New)
+}
+
+// Diff compares two configs field by field. Any change is detected, but the
+// Old and New values of a change are taken from the masked configs, so a
+// changed password shows up only as a changed path.
+func Diff(old, new *Config) []Change {
+	before := flatten(*old)
+	after := flatten(*new)
+	maskedBefore := flatten(old.Masked())
+	maskedAfter := flatten(new.Masked())
+
+	paths := make(map[string]struct{}, len(before))
+	for p := range before {
+		paths[p] = struct{}{}
+	}
+	for p := range after {
+		paths[p] = struct{}{}
+	}
+
+	var changes []Change
+	for p := range paths {
+		if before[p] != after[p] {
+			changes = append(changes, Change{Path: p, Old: maskedBefore[p], New: maskedAfter[p]})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// Reloadable reports whether the field at path can be applied without a
+// restart.
+func Reloadable(path string) bool {
+	for _, r := range reloadable {
+		if path == r || (strings.HasSuffix(r, ".") && strings.HasPrefix(path, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+func flatten(cfg Config) map[string]string {
+	out := make(map[string]string)
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return out
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(b, &tree); err != nil {
+		return out
+	}
+
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				walk(prefix+k+".", child)
+			}
+		default:
+			out[strings.TrimSuffix(prefix, ".")] = fmt.Sprint(v)
+		}
+	}
+	walk("", tree)
+
+	return out
+}
@@ -0,0 +1,206 @@
+package adminapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// App is the diagnostics listener. It only binds to localhost and has no
+// authentication, so it must never be exposed through a proxy.
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+	config     func() *config.Config
+	db         *sql.DB
+	amqp       Connector
+}
+
+// Connector reports the state of a broker connection.
+type Connector interface {
+	Connected() bool
+}
+
+// New creates the admin listener. config returns the config in effect, which
+// changes on reload.
+func New(
+	log *slog.Logger,
+	port int,
+	config func() *config.Config,
+	db *sql.DB,
+	amqp Connector,
+) *App {
+	a := &App{
+		log:    log,
+		port:   port,
+		config: config,
+		db:     db,
+		amqp:   amqp,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/buildinfo", a.buildInfo)
+	mux.HandleFunc("/config", a.effectiveConfig)
+	mux.HandleFunc("/status", a.status)
+
+	a.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return a
+}
+
+// Start binds localhost:port and serves in the background. Errors that stop
+// serving later on are sent to errs.
+func (a *App) Start(errs chan<- error) error {
+	const op = "adminapp.Start"
+
+	l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(a.port)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("admin server started", slog.String("addr", l.Addr().String()))
+
+	go func() {
+		if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("%s: %w", op, err)
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown waits for in-flight requests until ctx is done. Running profiles
+// are cut short then.
+func (a *App) Shutdown(ctx context.Context) error {
+	const op = "adminapp.Shutdown"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping admin server", slog.Int("port", a.port))
+
+	err := a.httpServer.Shutdown(ctx)
+	if err != nil {
+		_ = a.httpServer.Close()
+	}
+
+	return err
+}
+
+type buildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"`
+	Deps      map[string]string `json:"deps"`
+}
+
+func (a *App) buildInfo(w http.ResponseWriter, _ *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info is not available", http.StatusNotFound)
+		return
+	}
+
+	resp := buildInfo{
+		GoVersion: info.GoVersion,
+		Path:      info.Main.Path,
+		Version:   info.Main.Version,
+		Settings:  make(map[string]string, len(info.Settings)),
+		Deps:      make(map[string]string, len(info.Deps)),
+	}
+	for _, s := range info.Settings {
+		resp.Settings[s.Key] = s.Value
+	}
+	for _, d := range info.Deps {
+		resp.Deps[d.Path] = d.Version
+	}
+
+	writeJSON(w, resp)
+}
+
+func (a *App) effectiveConfig(w http.ResponseWriter, _ *http.Request) {
+	out, err := yaml.Marshal(a.config().Masked())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(out)
+}
+
+type status struct {
+	Goroutines int        `json:"goroutines"`
+	GOMAXPROCS int        `json:"gomaxprocs"`
+	HeapAlloc  uint64     `json:"heap_alloc_bytes"`
+	NumGC      uint32     `json:"num_gc"`
+	DB         *dbStatus  `json:"db,omitempty"`
+	AMQP       amqpStatus `json:"amqp"`
+}
+
+type dbStatus struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+}
+
+type amqpStatus struct {
+	Connected bool `json:"connected"`
+}
+
+func (a *App) status(w http.ResponseWriter, _ *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	resp := status{
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		HeapAlloc:  mem.HeapAlloc,
+		NumGC:      mem.NumGC,
+		AMQP:       amqpStatus{Connected: a.amqp.Connected()},
+	}
+	// There is no pool to report on with the memory storage driver.
+	if a.db != nil {
+		db := a.db.Stats()
+		resp.DB = &dbStatus{
+			MaxOpen:      db.MaxOpenConnections,
+			Open:         db.OpenConnections,
+			InUse:        db.InUse,
+			Idle:         db.Idle,
+			WaitCount:    db.WaitCount,
+			WaitDuration: db.WaitDuration.String(),
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -1,33 +1,154 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	adminapp "github.com/bxiit/order-service-pet-store/internal/app/admin"
 	grpcapp "github.com/bxiit/order-service-pet-store/internal/app/grpc"
-	"github.com/bxiit/order-service-pet-store/internal/data"
+	httpapp "github.com/bxiit/order-service-pet-store/internal/app/http"
+	"github.com/bxiit/order-service-pet-store/internal/creds"
+	"github.com/bxiit/order-service-pet-store/internal/events"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
 	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	"github.com/bxiit/order-service-pet-store/internal/services/report"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
 	"log/slog"
-	"time"
 )
 
 type App struct {
 	GRPCServer *grpcapp.App
+	HTTPServer *httpapp.App
+	*lifecycle
 }
 
+// New wires the application together. Nothing is started yet: call Start,
+// and Stop once Start has succeeded.
 func New(
 	log *slog.Logger,
-	grpcPort int,
-	dsn string,
-	tokenTTL time.Duration,
-) *App {
-	storage, err := data.New(dsn)
+	cfg *config.Config,
+	settings *settings.Settings,
+) (*App, error) {
+	const op = "app.New"
+
+	lc := newLifecycle(log, cfg.ShutdownTimeout)
+
+	storage, db, err := openStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	lc.append("storage", storage.Ping, func(context.Context) error {
+		return storage.Close()
+	})
+
+	ssoConn, err := dial(cfg.Clients.SSO.ClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	lc.append("sso client", nil, closeConn(ssoConn))
+
+	var catalogueClient cataloguev20.CatalogueServiceClient
+	if cfg.Clients.Catalogue.Address != "" {
+		catalogueConn, err := dial(cfg.Clients.Catalogue)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		lc.append("catalogue client", nil, closeConn(catalogueConn))
+		catalogueClient = cataloguev20.NewCatalogueServiceClient(catalogueConn)
+	}
+
+	publisher := events.NewAMQP(log, cfg.AMQP.URL, cfg.AMQP.Queue)
+	lc.append("amqp publisher", nil, publisher.Close)
+
+	identityProvider := identity.New(
+		log,
+		ssov1.NewAuthClient(ssoConn),
+		ssov1.NewUserInfoClient(ssoConn),
+		identity.Options{
+			Timeout:          cfg.Clients.SSO.Timeout,
+			Retries:          cfg.Clients.SSO.Retries,
+			CacheTTL:         cfg.Clients.SSO.CacheTTL,
+			BreakerThreshold: cfg.Clients.SSO.BreakerThreshold,
+			BreakerCooldown:  cfg.Clients.SSO.BreakerCooldown,
+		},
+	)
+
+	orderService := order.New(
+		log,
+		storage,
+		identityProvider,
+		catalogueClient,
+		settings,
+		publisher,
+		cfg.TokenTtl,
+	)
+
+	reportService := report.New(log, storage)
+	if cfg.Reports.UseViews {
+		refresher := report.NewRefresher(log, storage, cfg.Reports.RefreshInterval)
+		lc.append("report views refresher", refresher.Start, refresher.Stop)
+	}
+
+	importService := importer.New(log, storage, importer.DefaultBatchSize)
+
+	serverCreds, err := creds.Server(cfg.GRPC.TLS)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	orderService := order.New(log, storage, tokenTTL)
+	grpcApp := grpcapp.New(log, orderService, reportService, importService, identityProvider, settings, cfg.GRPC, cfg.Log.Payloads && cfg.Env != "prod", serverCreds)
+	lc.append("grpc server", func(context.Context) error {
+		return grpcApp.Start(lc.errs)
+	}, grpcApp.Shutdown)
+
+	var httpApp *httpapp.App
+	if cfg.HTTP.Port != 0 {
+		loopbackCreds, err := creds.Loopback(cfg.GRPC.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		httpApp = httpapp.New(log, cfg.HTTP.Port, cfg.GRPC.Port, loopbackCreds)
+		lc.append("http gateway", func(context.Context) error {
+			return httpApp.Start(lc.errs)
+		}, httpApp.Shutdown)
+	}
 
-	grpcApp := grpcapp.New(log, orderService, grpcPort)
+	if cfg.Admin.Port != 0 {
+		adminApp := adminapp.New(log, cfg.Admin.Port, settings.Config, db, publisher)
+		lc.append("admin server", func(context.Context) error {
+			return adminApp.Start(lc.errs)
+		}, adminApp.Shutdown)
+	}
 
 	return &App{
 		GRPCServer: grpcApp,
+		HTTPServer: httpApp,
+		lifecycle:  lc,
+	}, nil
+}
+
+func closeConn(conn *grpc.ClientConn) func(context.Context) error {
+	return func(context.Context) error {
+		return conn.Close()
 	}
 }
+
+func dial(cfg config.ClientConfig) (*grpc.ClientConn, error) {
+	const op = "app.dial"
+
+	clientCreds, err := creds.Client(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conn, err := grpc.NewClient(cfg.Address, grpc.WithTransportCredentials(clientCreds))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, cfg.Address, err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,207 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const requestIDHeader = "x-request-id"
+
+// redactedWords mark payload fields whose values never reach the log. They
+// are matched anywhere in the lower-cased field name, so accessToken,
+// refresh_token and clientSecret are redacted too.
+var redactedWords = []string{"token", "authorization", "password", "email", "secret"}
+
+// accessLog writes one line per RPC once it has finished. Payloads are only
+// added when logPayloads is set, and sensitive fields are redacted even then.
+type accessLog struct {
+	log         *slog.Logger
+	logPayloads bool
+}
+
+// callInfo collects what later interceptors learn about the caller, so the
+// access log line can report it.
+type callInfo struct {
+	userID atomic.Int32
+}
+
+type callInfoKey struct{}
+
+// recordUser remembers the authenticated user of the call for the access log.
+func recordUser(ctx context.Context, userID int32) {
+	if info, ok := ctx.Value(callInfoKey{}).(*callInfo); ok {
+		info.userID.Store(userID)
+	}
+}
+
+func (a *accessLog) Interceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, call, requestID := a.begin(ctx)
+
+	resp, err := handler(ctx, req)
+
+	attrs := a.attrs(ctx, info.FullMethod, start, call, requestID, err)
+	if a.logPayloads {
+		attrs = append(attrs, slog.String("request", redactPayload(req)))
+		if err == nil {
+			attrs = append(attrs, slog.String("response", redactPayload(resp)))
+		}
+	}
+
+	a.log.LogAttrs(ctx, accessLevel(status.Code(err)), "rpc finished", attrs...)
+
+	return resp, err
+}
+
+// StreamInterceptor logs streaming RPCs like Interceptor, without payloads.
+func (a *accessLog) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, call, requestID := a.begin(ss.Context())
+
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+
+	attrs := a.attrs(ctx, info.FullMethod, start, call, requestID, err)
+	a.log.LogAttrs(ctx, accessLevel(status.Code(err)), "rpc finished", attrs...)
+
+	return err
+}
+
+// begin sets up the request ID header and the callInfo of a call.
+func (a *accessLog) begin(ctx context.Context) (context.Context, *callInfo, string) {
+	requestID := incomingRequestID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+
+	call := &callInfo{}
+
+	return context.WithValue(ctx, callInfoKey{}, call), call, requestID
+}
+
+func (a *accessLog) attrs(ctx context.Context, method string, start time.Time, call *callInfo, requestID string, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("peer", peerAddr(ctx)),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("request_id", requestID),
+	}
+	if userID := call.userID.Load(); userID != 0 {
+		attrs = append(attrs, slog.Int("user_id", int(userID)))
+	}
+	if err != nil {
+		attrs = append(attrs, sl.Err(err))
+	}
+
+	return attrs
+}
+
+// contextStream replaces the context of a server stream, so values added by
+// stream interceptors reach the handler.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// accessLevel logs server-side failures as errors and caller mistakes as
+// warnings.
+func accessLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable, codes.DeadlineExceeded:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// incomingRequestID returns the request ID sent by the client, or a new one.
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(requestIDHeader); len(ids) > 0 && ids[0] != "" {
+		return ids[0]
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// redactPayload renders a message as JSON with sensitive fields replaced.
+func redactPayload(v interface{}) string {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ""
+	}
+
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+
+	var fields interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return ""
+	}
+
+	b, err = json.Marshal(redact(fields))
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
+func sensitive(field string) bool {
+	field = strings.ToLower(field)
+	for _, word := range redactedWords {
+		if strings.Contains(field, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitive(key) {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			v[key] = redact(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+
+	return v
+}
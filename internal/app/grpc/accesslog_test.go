@@ -0,0 +1,155 @@
+package grpcapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"log/slog"
+	"testing"
+)
+
+func newTestAccessLog(logPayloads bool) (*accessLog, *bytes.Buffer) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return &accessLog{log: log, logPayloads: logPayloads}, &buf
+}
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, buf)
+	}
+	return record
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		level string
+	}{
+		{"ok", nil, "INFO"},
+		{"caller mistake", status.Error(codes.InvalidArgument, "bad"), "WARN"},
+		{"not found", status.Error(codes.NotFound, "missing"), "WARN"},
+		{"server failure", status.Error(codes.Internal, "boom"), "ERROR"},
+		{"unavailable", status.Error(codes.Unavailable, "down"), "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, buf := newTestAccessLog(false)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "req-1"))
+
+			_, err := a.Interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/GetOrder"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				recordUser(ctx, 7)
+				return nil, tt.err
+			})
+			if err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			record := decodeRecord(t, buf)
+			want := map[string]interface{}{
+				"level":      tt.level,
+				"msg":        "rpc finished",
+				"method":     "/order.OrderService/GetOrder",
+				"code":       status.Code(tt.err).String(),
+				"request_id": "req-1",
+				"user_id":    7.0,
+			}
+			for k, v := range want {
+				if record[k] != v {
+					t.Errorf("%s: got %v, want %v", k, record[k], v)
+				}
+			}
+			if _, ok := record["request"]; ok {
+				t.Error("payload logged")
+			}
+		})
+	}
+}
+
+func TestAccessLog_Payloads(t *testing.T) {
+	a, buf := newTestAccessLog(true)
+	req := &ssov1.LoginRequest{Email: "a@example.com", Password: "hunter2", AppId: 1}
+	resp := &ssov1.LoginResponse{Token: "secret-token"}
+
+	_, err := a.Interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/Login"}, func(context.Context, interface{}) (interface{}, error) {
+		return resp, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := decodeRecord(t, buf)
+	if got, want := record["request"], `{"appId":1,"email":"[REDACTED]","password":"[REDACTED]"}`; got != want {
+		t.Errorf("request: got %v, want %v", got, want)
+	}
+	if got, want := record["response"], `{"token":"[REDACTED]"}`; got != want {
+		t.Errorf("response: got %v, want %v", got, want)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("hunter2")) || bytes.Contains(buf.Bytes(), []byte("secret-token")) {
+		t.Errorf("secret logged: %s", buf)
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"name":          "bowl",
+		"accessToken":   "t1",
+		"Refresh_Token": "t2",
+		"clientSecret":  "s1",
+		"PASSWORD_HASH": "h1",
+		"users":         []interface{}{map[string]interface{}{"id": 1, "api_token": "t3"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := redactPayload(msg)
+	want := `{"PASSWORD_HASH":"[REDACTED]","Refresh_Token":"[REDACTED]","accessToken":"[REDACTED]","clientSecret":"[REDACTED]","name":"bowl","users":[{"api_token":"[REDACTED]","id":1}]}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestAccessLog_RequestID(t *testing.T) {
+	a, buf := newTestAccessLog(false)
+
+	_, _ = a.Interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/GetOrder"}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	record := decodeRecord(t, buf)
+	if id, _ := record["request_id"].(string); len(id) != 16 {
+		t.Errorf("got request id %q", id)
+	}
+	if _, ok := record["user_id"]; ok {
+		t.Error("user id logged for an anonymous call")
+	}
+}
+
+func TestAccessLog_Stream(t *testing.T) {
+	a, buf := newTestAccessLog(false)
+	ss := &fakeStream{ctx: context.Background()}
+
+	err := a.StreamInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/order.OrderService/ImportOrders"}, func(_ interface{}, stream grpc.ServerStream) error {
+		recordUser(stream.Context(), 1)
+		return status.Error(codes.PermissionDenied, "permission failed")
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got %v", err)
+	}
+
+	record := decodeRecord(t, buf)
+	if record["level"] != "WARN" || record["code"] != "PermissionDenied" || record["user_id"] != 1.0 {
+		t.Errorf("got %v", record)
+	}
+}
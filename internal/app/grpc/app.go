@@ -1,17 +1,18 @@
 package grpcapp
 
 import (
+	"context"
 	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
 	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
-	orderv1 "github.com/bxiit/protos/gen/go/order"
-	ssov1 "github.com/bxiit/protos/gen/go/sso"
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
-	"log"
 	"log/slog"
 	"net"
 )
@@ -22,23 +23,17 @@ type App struct {
 	port       int
 }
 
-var AuthServiceClient ssov1.AuthClient
-var OrderServiceClient orderv1.OrderServiceClient
-var UserInfoServiceClient ssov1.UserInfoClient
-
 func New(
 	log *slog.Logger,
 	catalogueService orderGrpc.OrderService,
-	port int,
+	reportService orderGrpc.ReportService,
+	importer orderGrpc.Importer,
+	identity identity.Provider,
+	settings *settings.Settings,
+	cfg config.GRPCConfig,
+	logPayloads bool,
+	creds credentials.TransportCredentials,
 ) *App {
-	loggingOpts := []logging.Option{
-		logging.WithLogOnEvents(
-			//logging.StartCall, logging.FinishCall,
-			logging.PayloadReceived, logging.PayloadSent,
-		),
-		// Add any other option (check functions starting with logging.With).
-	}
-
 	recoveryOpts := []recovery.Option{
 		recovery.WithRecoveryHandler(func(p interface{}) (err error) {
 			log.Error("Recovered from panic", slog.Any("panic", p))
@@ -47,19 +42,69 @@ func New(
 		}),
 	}
 
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
-		recovery.UnaryServerInterceptor(recoveryOpts...),
-		logging.UnaryServerInterceptor(InterceptorLogger(log), loggingOpts...),
-		AdminInterceptorCreateItem,
-		AdminInterceptorGetAllOrders,
-		AdminInterceptorGetOrdersOfUser,
-	))
+	auth := &authInterceptors{
+		identity: identity,
+		settings: settings,
+	}
+
+	accessLog := &accessLog{
+		log:         log,
+		logPayloads: logPayloads,
+	}
+
+	limiter := newRateLimiter(identity, settings)
+
+	deadlines := &deadlines{
+		timeout: cfg.Timeout,
+		methods: cfg.MethodTimeouts,
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.Creds(creds),
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: cfg.Keepalive.MaxConnectionIdle,
+			Time:              cfg.Keepalive.Time,
+			Timeout:           cfg.Keepalive.Timeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.Keepalive.MinTime,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}),
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+
+	gRPCServer := grpc.NewServer(append(serverOpts,
+		grpc.ChainUnaryInterceptor(
+			recovery.UnaryServerInterceptor(recoveryOpts...),
+			accessLog.Interceptor,
+			deadlines.Interceptor,
+			limiter.Interceptor,
+			auth.AdminInterceptorGetAllOrders,
+			auth.AdminInterceptorGetOrdersOfUser,
+			auth.AdminOnly(
+				orderGrpc.CancelOrderMethod,
+				orderGrpc.SetLogLevelMethod,
+				orderGrpc.SearchOrdersMethod,
+				orderGrpc.SalesReportMethod,
+				orderGrpc.TopItemsReportMethod,
+				orderGrpc.UserOrdersReportMethod,
+			),
+		),
+		grpc.ChainStreamInterceptor(
+			recovery.StreamServerInterceptor(recoveryOpts...),
+			accessLog.StreamInterceptor,
+			auth.AdminOnlyStream(orderGrpc.ImportOrdersMethod),
+		),
+	)...)
 
-	orderGrpc.Register(gRPCServer, catalogueService)
+	orderGrpc.Register(gRPCServer, catalogueService, reportService, importer, settings)
 
 	return &App{
 		log:        log,
-		port:       port,
+		port:       cfg.Port,
 		grpcServer: gRPCServer,
 	}
 }
@@ -80,22 +125,40 @@ func (a *App) Run() error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	a.log.Info("grpc server started", slog.String("addr", l.Addr().String()))
+	return a.Serve(l)
+}
 
-	if err := a.grpcServer.Serve(l); err != nil {
+// Start binds the port and serves in the background. Errors that stop
+// serving later on are sent to errs.
+func (a *App) Start(errs chan<- error) error {
+	const op = "grpcapp.Start"
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	go func() {
+		if err := a.Serve(l); err != nil {
+			errs <- err
+		}
+	}()
+
 	return nil
 }
 
-func ConnectToSsoService() {
-	conn, err := grpc.NewClient("0.0.0.0:44044", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("failed to connect to auth service: %v", err)
+// Serve serves on l until the server stops. Tests pass an in-memory
+// listener.
+func (a *App) Serve(l net.Listener) error {
+	const op = "grpcapp.Serve"
+
+	a.log.Info("grpc server started", slog.String("addr", l.Addr().String()))
+
+	if err := a.grpcServer.Serve(l); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	AuthServiceClient = ssov1.NewAuthClient(conn)
-	UserInfoServiceClient = ssov1.NewUserInfoClient(conn)
+
+	return nil
 }
 
 func (a *App) Stop() {
@@ -106,3 +169,26 @@ func (a *App) Stop() {
 
 	a.grpcServer.GracefulStop()
 }
+
+// Shutdown waits for in-flight RPCs to finish until ctx is done and then
+// closes every connection.
+func (a *App) Shutdown(ctx context.Context) error {
+	const op = "grpcapp.Shutdown"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping gRPC server", slog.Int("port", a.port))
+
+	done := make(chan struct{})
+	go func() {
+		a.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.grpcServer.Stop()
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
@@ -0,0 +1,48 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path"
+	"time"
+)
+
+// deadlines bounds every RPC by the timeout configured for its method, or by
+// the default one. context.WithTimeout keeps a shorter deadline sent by the
+// client, and the bounded context reaches SQL, SSO and AMQP calls through
+// the handler.
+type deadlines struct {
+	timeout time.Duration
+	methods map[string]time.Duration
+}
+
+func (d *deadlines) Interceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	timeout := d.timeout
+	if t, ok := d.methods[path.Base(info.FullMethod)]; ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	// Handlers tend to hide the cause behind codes.Internal, so report an
+	// expired or cancelled call as what it is.
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case errors.Is(ctx.Err(), context.Canceled):
+		return nil, status.Error(codes.Canceled, "request cancelled")
+	}
+
+	return nil, err
+}
@@ -0,0 +1,93 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestDeadlines(t *testing.T) {
+	d := &deadlines{
+		timeout: time.Minute,
+		methods: map[string]time.Duration{"ListOrders": time.Hour, "GetOrder": 0},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		ctx    func() (context.Context, context.CancelFunc)
+		want   time.Duration
+	}{
+		{"default", "/order.OrderService/CreateOrder", nil, time.Minute},
+		{"method", "/order.OrderService/ListOrders", nil, time.Hour},
+		{"disabled", "/order.OrderService/GetOrder", nil, 0},
+		{"client deadline", "/order.OrderService/ListOrders", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Second)
+		}, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				var cancel context.CancelFunc
+				ctx, cancel = tt.ctx()
+				defer cancel()
+			}
+
+			var deadline time.Time
+			var ok bool
+			_, err := d.Interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				deadline, ok = ctx.Deadline()
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == 0 {
+				if ok {
+					t.Errorf("got deadline %v", deadline)
+				}
+				return
+			}
+			if left := time.Until(deadline); !ok || left > tt.want || left < tt.want-time.Second {
+				t.Errorf("got %v left, want about %v", left, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadlines_Errors(t *testing.T) {
+	d := &deadlines{timeout: 10 * time.Millisecond}
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/ListOrders"}
+	hidden := status.Error(codes.Internal, "failed to list orders")
+
+	_, err := d.Interceptor(context.Background(), nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, hidden
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expired: got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = d.Interceptor(ctx, nil, info, func(context.Context, interface{}) (interface{}, error) {
+		cancel()
+		return nil, hidden
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("cancelled: got %v", err)
+	}
+
+	_, err = d.Interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, hidden
+	})
+	if !errors.Is(err, hidden) {
+		t.Errorf("failed: got %v", err)
+	}
+}
@@ -2,17 +2,16 @@ package grpcapp
 
 import (
 	"context"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
 	orderv1 "github.com/bxiit/protos/gen/go/order"
 	ssov1 "github.com/bxiit/protos/gen/go/sso"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
-	"github.com/jinzhu/copier"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"log"
-	"log/slog"
 )
 
 type TokenClaims struct {
@@ -22,6 +21,12 @@ type TokenClaims struct {
 	jwt.MapClaims
 }
 
+// authInterceptors guard order RPCs by asking the SSO service who the caller is.
+type authInterceptors struct {
+	identity identity.Provider
+	settings *settings.Settings
+}
+
 func DecodeToken(appSecret string, tokenString string) (*TokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(appSecret), nil
@@ -38,117 +43,119 @@ func DecodeToken(appSecret string, tokenString string) (*TokenClaims, error) {
 	}
 }
 
-func AdminInterceptorCreateItem(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if info.FullMethod != "/order.CatalogueService/CreateOrder" {
+// AdminInterceptorGetOrdersOfUser lets admins list their own orders by
+// user id.
+func (i *authInterceptors) AdminInterceptorGetOrdersOfUser(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != "/order.OrderService/GetOrderByUserId" {
 		return handler(ctx, req)
 	}
 
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		log.Printf("failed to get metadata from context")
-	}
-	tkn, found := md["authorization"]
-	if !found && len(tkn) == 0 {
-		return nil, status.Errorf(codes.Unauthenticated, "authentication is required")
-	}
-
-	userInfo, err := UserInfoServiceClient.GetUserInfo(ctx, &ssov1.GetUserInfoRequest{Token: tkn[0]})
+	user, err := i.requireAdmin(ctx)
 	if err != nil {
-		log.Printf("failed to get user info from sso service")
-		return nil, status.Errorf(codes.Internal, "failed to get user info from sso service")
+		return nil, err
 	}
 
-	if userInfo.User.Role != "admin" {
-		return nil, status.Errorf(codes.Internal, "permission failed")
+	request, ok := req.(*orderv1.GetOrdersByUserId)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected request type %T", req)
+	}
+	if user.Id != request.GetUserId() {
+		return nil, status.Errorf(codes.InvalidArgument, "you can not get access to others orders")
 	}
 
 	return handler(ctx, req)
 }
 
-func AdminInterceptorGetOrdersOfUser(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if info.FullMethod != "/order.OrderService/GetOrderByUserId" {
+// AdminInterceptorGetAllOrders lets only admins list every order.
+func (i *authInterceptors) AdminInterceptorGetAllOrders(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != "/order.OrderService/ListOrders" {
 		return handler(ctx, req)
 	}
 
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		log.Printf("failed to get metadata from context")
-	}
-	tkn, found := md["authorization"]
-	if !found && len(tkn) == 0 {
-		return nil, status.Errorf(codes.Unauthenticated, "authentication is required")
+	if _, err := i.requireAdmin(ctx); err != nil {
+		return nil, err
 	}
 
-	userInfo, err := UserInfoServiceClient.GetUserInfo(ctx, &ssov1.GetUserInfoRequest{Token: tkn[0]})
-	if err != nil {
-		log.Printf("failed to get user info from sso service")
-		return nil, status.Errorf(codes.Internal, "failed to get user info from sso service")
-	}
+	return handler(ctx, req)
+}
 
-	var request orderv1.GetOrdersByUserId
-	err = copier.Copy(&request, req)
-	if err != nil {
-		return nil, err
-	}
-	if userInfo.User.Id != request.UserId {
-		return nil, status.Errorf(codes.InvalidArgument, "you can not get access to others orders")
+// AdminOnly lets only callers with the admin role of the auth policy call
+// the given methods.
+func (i *authInterceptors) AdminOnly(methods ...string) grpc.UnaryServerInterceptor {
+	guarded := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		guarded[m] = true
 	}
 
-	isAdminRequest := &ssov1.IsAdminRequest{UserId: int64(userInfo.User.Id)}
-	isAdminResponse, err := AuthServiceClient.IsAdmin(ctx, isAdminRequest)
-	if err != nil {
-		log.Printf("permissions fail %v", err)
-		return nil, status.Errorf(codes.PermissionDenied, "permission failed")
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !guarded[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		if _, err := i.requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
 	}
+}
 
-	if !isAdminResponse.IsAdmin {
-		return nil, status.Errorf(codes.PermissionDenied, "permission failed")
+// AdminOnlyStream is AdminOnly for streaming methods.
+func (i *authInterceptors) AdminOnlyStream(methods ...string) grpc.StreamServerInterceptor {
+	guarded := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		guarded[m] = true
 	}
 
-	return handler(ctx, req)
-}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !guarded[info.FullMethod] {
+			return handler(srv, ss)
+		}
 
-func AdminInterceptorGetAllOrders(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if info.FullMethod != "/order.OrderService/ListOrders" {
-		return handler(ctx, req)
+		if _, err := i.requireAdmin(ss.Context()); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
 	}
+}
 
+// requireAdmin checks that the caller's token belongs to an SSO user with
+// the admin role of the current auth policy, and returns that user.
+func (i *authInterceptors) requireAdmin(ctx context.Context) (*ssov1.User, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		log.Printf("failed to get metadata from context")
 	}
 	tkn, found := md["authorization"]
 	if !found || len(tkn) == 0 || tkn[0] == "" {
-		return nil, status.Errorf(codes.PermissionDenied, "lack of permission")
+		return nil, status.Errorf(codes.Unauthenticated, "authentication is required")
 	}
 
-	userInfo, err := UserInfoServiceClient.GetUserInfo(ctx, &ssov1.GetUserInfoRequest{Token: tkn[0]})
+	user, err := i.identity.UserInfo(ctx, tkn[0])
 	if err != nil {
 		log.Printf("failed to get user info from sso service")
-		return nil, status.Errorf(codes.Internal, "failed to get user info from sso service")
+		return nil, ssoError(err, codes.Internal, "failed to get user info from sso service")
 	}
+	recordUser(ctx, user.Id)
 
-	isAdminRequest := &ssov1.IsAdminRequest{UserId: int64(userInfo.User.Id)}
-	isAdminResponse, err := AuthServiceClient.IsAdmin(ctx, isAdminRequest)
-	if err != nil {
-		log.Printf("permissions fail %v", err)
-		return nil, status.Errorf(codes.Internal, "permission failed")
-	}
-
-	if !isAdminResponse.IsAdmin {
-		return nil, status.Errorf(codes.Internal, "permission failed")
+	if user.Role != i.settings.Auth().AdminRole {
+		return nil, status.Errorf(codes.PermissionDenied, "permission failed")
 	}
 
-	return handler(ctx, req)
+	return user, nil
 }
 
-func InterceptorLogger(l *slog.Logger) logging.Logger {
-	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
-		l.Log(ctx, slog.Level(lvl), msg, fields...)
-	})
+// ssoError reports an unavailable SSO service as such and hides any other
+// SSO failure behind the given code and message.
+func ssoError(err error, code codes.Code, msg string) error {
+	if status.Code(err) == codes.Unavailable {
+		return status.Error(codes.Unavailable, "sso service is unavailable")
+	}
+	return status.Error(code, msg)
 }
 
-func OrderInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+func (i *authInterceptors) OrderInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	if info.FullMethod != "/order.OrderService/CreateOrder" {
 		return handler(ctx, req)
 	}
@@ -163,14 +170,13 @@ func OrderInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServ
 		return nil, status.Errorf(codes.Unauthenticated, "authentication is required")
 	}
 
-	isAuthenticatedRequest := &ssov1.IsAuthenticatedRequest{Token: tkn[0]}
-	isAuthenticatedResponse, err := AuthServiceClient.IsAuthenticated(ctx, isAuthenticatedRequest)
+	isAuthenticated, err := i.identity.IsAuthenticated(ctx, tkn[0])
 	if err != nil {
 		log.Printf("failed to get metadata from context")
-		return nil, status.Errorf(codes.Internal, "failed to get metadata from context")
+		return nil, ssoError(err, codes.Internal, "failed to get metadata from context")
 	}
 
-	if !isAuthenticatedResponse.GetIsAuthenticated() {
+	if !isAuthenticated {
 		return nil, status.Errorf(codes.Unauthenticated, "authentication is required")
 	}
 
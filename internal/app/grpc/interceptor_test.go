@@ -0,0 +1,313 @@
+package grpcapp
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	orderv1 "github.com/bxiit/protos/gen/go/order"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"testing"
+)
+
+// Tokens known to fakeIdentity.
+const (
+	adminToken    = "admin-token"
+	customerToken = "customer-token"
+)
+
+// fakeIdentity knows an admin with id 1 and a customer with id 2. err, when
+// set, fails every lookup; uncached keeps CachedUserInfo from answering.
+type fakeIdentity struct {
+	err           error
+	uncached      bool
+	userInfoCalls int
+}
+
+func (f *fakeIdentity) CachedUserInfo(token string) (*ssov1.User, bool) {
+	if f.uncached || f.err != nil {
+		return nil, false
+	}
+	user, err := f.lookup(token)
+	return user, err == nil
+}
+
+func (f *fakeIdentity) UserInfo(_ context.Context, token string) (*ssov1.User, error) {
+	f.userInfoCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.lookup(token)
+}
+
+func (f *fakeIdentity) lookup(token string) (*ssov1.User, error) {
+	switch token {
+	case adminToken:
+		return &ssov1.User{Id: 1, Role: "admin"}, nil
+	case customerToken:
+		return &ssov1.User{Id: 2, Role: "customer"}, nil
+	}
+	return nil, status.Error(codes.Unauthenticated, "unknown token")
+}
+
+func (f *fakeIdentity) IsAdmin(_ context.Context, userId int64) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return userId == 1, nil
+}
+
+func (f *fakeIdentity) IsAuthenticated(_ context.Context, token string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return token == adminToken || token == customerToken, nil
+}
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// caller is how a test case calls: without metadata, with metadata but no
+// token, or with the given token.
+type caller int
+
+const (
+	noMetadata caller = iota
+	noToken
+	emptyToken
+	unknownToken
+	customer
+	admin
+)
+
+func (c caller) context() context.Context {
+	ctx := context.Background()
+	switch c {
+	case noMetadata:
+		return ctx
+	case noToken:
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "1"))
+	case emptyToken:
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", ""))
+	case unknownToken:
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "forged"))
+	case customer:
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", customerToken))
+	default:
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", adminToken))
+	}
+}
+
+type authCase struct {
+	name   string
+	caller caller
+	sso    error
+	want   codes.Code
+}
+
+func newAuthInterceptors(sso error) *authInterceptors {
+	cfg := &config.Config{Auth: config.AuthConfig{AdminRole: "admin"}}
+	return &authInterceptors{
+		identity: &fakeIdentity{err: sso},
+		settings: settings.New(cfg, new(slog.LevelVar)),
+	}
+}
+
+// runUnary calls interceptor for method and returns the status code, which
+// is OK when the handler ran.
+func runUnary(t *testing.T, interceptor grpc.UnaryServerInterceptor, ctx context.Context, method string, req interface{}) codes.Code {
+	t.Helper()
+
+	called := false
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return "ok", nil
+	}
+
+	_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	if (err == nil) != called {
+		t.Errorf("handler called: %v, error: %v", called, err)
+	}
+	return status.Code(err)
+}
+
+func TestAdminOnly(t *testing.T) {
+	const method = "/order.OrderService/CancelOrder"
+
+	tests := []authCase{
+		{"no metadata", noMetadata, nil, codes.Unauthenticated},
+		{"no token", noToken, nil, codes.Unauthenticated},
+		{"empty token", emptyToken, nil, codes.Unauthenticated},
+		{"unknown token", unknownToken, nil, codes.Internal},
+		{"customer", customer, nil, codes.PermissionDenied},
+		{"admin", admin, nil, codes.OK},
+		{"sso unavailable", admin, errUnavailable, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newAuthInterceptors(tt.sso)
+			interceptor := i.AdminOnly(method, "/order.OrderService/SearchOrders")
+
+			if got := runUnary(t, interceptor, tt.caller.context(), method, nil); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := runUnary(t, interceptor, tt.caller.context(), "/order.OrderService/GetOrder", nil); got != codes.OK {
+				t.Errorf("unguarded method: got %v", got)
+			}
+		})
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAdminOnly_Reload(t *testing.T) {
+	const method = "/order.OrderService/CancelOrder"
+
+	i := newAuthInterceptors(nil)
+	interceptor := i.AdminOnly(method)
+
+	i.settings.Apply(&config.Config{Auth: config.AuthConfig{AdminRole: "customer"}})
+	if got := runUnary(t, interceptor, customer.context(), method, nil); got != codes.OK {
+		t.Errorf("customer: got %v", got)
+	}
+	if got := runUnary(t, interceptor, admin.context(), method, nil); got != codes.PermissionDenied {
+		t.Errorf("admin: got %v", got)
+	}
+}
+
+func TestAdminOnlyStream(t *testing.T) {
+	const method = "/order.OrderService/ImportOrders"
+
+	tests := []authCase{
+		{"no token", noToken, nil, codes.Unauthenticated},
+		{"unknown token", unknownToken, nil, codes.Internal},
+		{"customer", customer, nil, codes.PermissionDenied},
+		{"admin", admin, nil, codes.OK},
+		{"sso unavailable", customer, errUnavailable, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := newAuthInterceptors(tt.sso).AdminOnlyStream(method)
+
+			for _, m := range []string{method, "/order.OrderService/Other"} {
+				called := false
+				handler := func(interface{}, grpc.ServerStream) error {
+					called = true
+					return nil
+				}
+
+				err := interceptor(nil, &fakeStream{ctx: tt.caller.context()}, &grpc.StreamServerInfo{FullMethod: m}, handler)
+				want := tt.want
+				if m != method {
+					want = codes.OK
+				}
+				if got := status.Code(err); got != want || called != (want == codes.OK) {
+					t.Errorf("%s: got %v, handler called: %v, want %v", m, got, called, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminInterceptorGetAllOrders(t *testing.T) {
+	const method = "/order.OrderService/ListOrders"
+
+	tests := []authCase{
+		{"no metadata", noMetadata, nil, codes.Unauthenticated},
+		{"no token", noToken, nil, codes.Unauthenticated},
+		{"empty token", emptyToken, nil, codes.Unauthenticated},
+		{"unknown token", unknownToken, nil, codes.Internal},
+		{"customer", customer, nil, codes.PermissionDenied},
+		{"admin", admin, nil, codes.OK},
+		{"sso unavailable", admin, errUnavailable, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newAuthInterceptors(tt.sso)
+
+			if got := runUnary(t, i.AdminInterceptorGetAllOrders, tt.caller.context(), method, nil); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := runUnary(t, i.AdminInterceptorGetAllOrders, tt.caller.context(), "/order.OrderService/GetOrder", nil); got != codes.OK {
+				t.Errorf("other method: got %v", got)
+			}
+		})
+	}
+}
+
+func TestAdminInterceptorGetOrdersOfUser(t *testing.T) {
+	const method = "/order.OrderService/GetOrderByUserId"
+
+	tests := []struct {
+		authCase
+		userId int32
+	}{
+		{authCase{"no metadata", noMetadata, nil, codes.Unauthenticated}, 1},
+		{authCase{"no token", noToken, nil, codes.Unauthenticated}, 1},
+		{authCase{"unknown token", unknownToken, nil, codes.Internal}, 1},
+		{authCase{"customer, own orders", customer, nil, codes.PermissionDenied}, 2},
+		{authCase{"customer, other orders", customer, nil, codes.PermissionDenied}, 1},
+		{authCase{"admin, own orders", admin, nil, codes.OK}, 1},
+		{authCase{"admin, other orders", admin, nil, codes.InvalidArgument}, 2},
+		{authCase{"sso unavailable", admin, errUnavailable, codes.Unavailable}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newAuthInterceptors(tt.sso)
+			req := &orderv1.GetOrdersByUserId{UserId: tt.userId}
+
+			if got := runUnary(t, i.AdminInterceptorGetOrdersOfUser, tt.caller.context(), method, req); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderInterceptor(t *testing.T) {
+	const method = "/order.OrderService/CreateOrder"
+
+	tests := []authCase{
+		{"no metadata", noMetadata, nil, codes.Unauthenticated},
+		{"no token", noToken, nil, codes.Unauthenticated},
+		{"unknown token", unknownToken, nil, codes.Unauthenticated},
+		{"customer", customer, nil, codes.OK},
+		{"admin", admin, nil, codes.OK},
+		{"sso unavailable", customer, errUnavailable, codes.Unavailable},
+		{"sso failing", customer, status.Error(codes.Internal, "boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := newAuthInterceptors(tt.sso)
+
+			if got := runUnary(t, i.OrderInterceptor, tt.caller.context(), method, nil); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordUser(t *testing.T) {
+	call := &callInfo{}
+	ctx := context.WithValue(customer.context(), callInfoKey{}, call)
+
+	runUnary(t, newAuthInterceptors(nil).AdminOnly("/order.OrderService/CancelOrder"), ctx, "/order.OrderService/CancelOrder", nil)
+
+	if got := call.userID.Load(); got != 2 {
+		t.Errorf("recorded user %d, want 2", got)
+	}
+}
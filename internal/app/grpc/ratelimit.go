@@ -0,0 +1,161 @@
+package grpcapp
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"math"
+	"net"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucketIdleTTL is how long an unused bucket is kept before it is dropped.
+// A bucket idle that long has normally refilled, so dropping it is harmless.
+const bucketIdleTTL = 10 * time.Minute
+
+// rateLimiter throttles callers with one token bucket per caller and method.
+// Limits come from settings on every call, so a reload applies at once.
+type rateLimiter struct {
+	identity identity.Provider
+	settings *settings.Settings
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	caller string
+	method string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(identity identity.Provider, settings *settings.Settings) *rateLimiter {
+	return &rateLimiter{
+		identity: identity,
+		settings: settings,
+		now:      time.Now,
+		buckets:  make(map[bucketKey]*bucket),
+	}
+}
+
+func (r *rateLimiter) Interceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	limit := r.settings.RateLimits().For(method)
+	if limit.RPS <= 0 {
+		return handler(ctx, req)
+	}
+
+	caller, token := r.caller(ctx)
+	wait := r.take(bucketKey{caller: caller, method: method}, limit)
+	if wait > 0 {
+		return nil, rateLimitError(ctx, wait)
+	}
+
+	if token != "" {
+		// Admitted on the peer's budget; resolving the token now lets the
+		// caller's next requests count against the user's own.
+		if user, err := r.identity.UserInfo(ctx, token); err == nil {
+			recordUser(ctx, user.Id)
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// caller identifies who is calling: the authenticated user when the token is
+// in the identity cache, the peer address otherwise. SSO is not asked before
+// the limiter has run, so a flood of made-up tokens is throttled by peer
+// instead of reaching SSO. A token still to be resolved is returned.
+func (r *rateLimiter) caller(ctx context.Context) (caller string, token string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tkn := md.Get("authorization"); len(tkn) > 0 && tkn[0] != "" {
+		if user, ok := r.identity.CachedUserInfo(tkn[0]); ok {
+			recordUser(ctx, user.Id)
+			return "user:" + strconv.Itoa(int(user.Id)), ""
+		}
+		token = tkn[0]
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "peer:" + host, token
+	}
+
+	return "peer:unknown", token
+}
+
+// take spends one token from the bucket of key and returns zero, or, when
+// the bucket is empty, how long until a token is available.
+func (r *rateLimiter) take(key bucketKey, limit config.RateLimit) time.Duration {
+	now := r.now()
+	burst := math.Max(float64(limit.Burst), 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		r.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.RPS)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.RPS * float64(time.Second))
+	}
+	b.tokens--
+
+	return 0
+}
+
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < bucketIdleTTL {
+		return
+	}
+	r.lastSweep = now
+
+	for key, b := range r.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// rateLimitError reports ResourceExhausted with the wait both as a
+// retry-after header, in whole seconds, and as a RetryInfo detail.
+func rateLimitError(ctx context.Context, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry in %ds", seconds))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = detailed
+	}
+
+	return st.Err()
+}
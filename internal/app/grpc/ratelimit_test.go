@@ -0,0 +1,173 @@
+package grpcapp
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(limits config.RateLimitConfig) (*rateLimiter, *time.Time) {
+	cfg := &config.Config{RateLimits: limits}
+	r := newRateLimiter(&fakeIdentity{}, settings.New(cfg, new(slog.LevelVar)))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	return r, &now
+}
+
+func TestRateLimiter(t *testing.T) {
+	r, now := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{RPS: 2, Burst: 3},
+	})
+	const method = "/order.OrderService/GetOrder"
+
+	for i := 0; i < 3; i++ {
+		if got := runUnary(t, r.Interceptor, customer.context(), method, nil); got != codes.OK {
+			t.Fatalf("call %d: got %v", i, got)
+		}
+	}
+
+	_, err := r.Interceptor(customer.context(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+		t.Error("handler called over the limit")
+		return nil, nil
+	})
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted || st.Message() != "rate limit exceeded, retry in 1s" {
+		t.Fatalf("got %v", err)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("got details %v", st.Details())
+	}
+	if info, ok := st.Details()[0].(*errdetails.RetryInfo); !ok || info.GetRetryDelay().AsDuration() != 500*time.Millisecond {
+		t.Errorf("got detail %v", st.Details()[0])
+	}
+
+	// Other callers have their own bucket.
+	if got := runUnary(t, r.Interceptor, admin.context(), method, nil); got != codes.OK {
+		t.Errorf("admin: got %v", got)
+	}
+
+	// Half a second refills one token, only one.
+	*now = now.Add(500 * time.Millisecond)
+	if got := runUnary(t, r.Interceptor, customer.context(), method, nil); got != codes.OK {
+		t.Errorf("after refill: got %v", got)
+	}
+	if got := runUnary(t, r.Interceptor, customer.context(), method, nil); got != codes.ResourceExhausted {
+		t.Errorf("after refill: got %v", got)
+	}
+}
+
+func TestRateLimiter_Methods(t *testing.T) {
+	r, _ := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{RPS: 1, Burst: 1},
+		Methods: map[string]config.RateLimit{
+			"ListOrders": {},
+		},
+	})
+
+	for i := 0; i < 5; i++ {
+		if got := runUnary(t, r.Interceptor, customer.context(), "/order.OrderService/ListOrders", nil); got != codes.OK {
+			t.Fatalf("unlimited method, call %d: got %v", i, got)
+		}
+	}
+
+	// Buckets are per method.
+	for _, method := range []string{"/order.OrderService/GetOrder", "/order.OrderService/CreateOrder"} {
+		if got := runUnary(t, r.Interceptor, customer.context(), method, nil); got != codes.OK {
+			t.Errorf("%s: got %v", method, got)
+		}
+	}
+	if got := runUnary(t, r.Interceptor, customer.context(), "/order.OrderService/GetOrder", nil); got != codes.ResourceExhausted {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRateLimiter_Caller(t *testing.T) {
+	r, _ := newTestLimiter(config.RateLimitConfig{})
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 51000}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		want      string
+		wantToken string
+	}{
+		{"cached user", customer.context(), "user:2", ""},
+		{"unknown token", peer.NewContext(unknownToken.context(), &peer.Peer{Addr: addr}), "peer:10.0.0.7", "forged"},
+		{"no token", peer.NewContext(context.Background(), &peer.Peer{Addr: addr}), "peer:10.0.0.7", ""},
+		{"no peer", context.Background(), "peer:unknown", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, token := r.caller(tt.ctx)
+			if got != tt.want || token != tt.wantToken {
+				t.Errorf("got %q, %q, want %q, %q", got, token, tt.want, tt.wantToken)
+			}
+		})
+	}
+
+	if calls := r.identity.(*fakeIdentity).userInfoCalls; calls != 0 {
+		t.Errorf("caller asked SSO %d times", calls)
+	}
+}
+
+func TestRateLimiter_UncachedTokens(t *testing.T) {
+	r, _ := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{RPS: 1, Burst: 2},
+	})
+	identity := r.identity.(*fakeIdentity)
+	identity.uncached = true
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 51000}
+	const method = "/order.OrderService/GetOrder"
+
+	// Made-up tokens share the peer's bucket and only the admitted calls
+	// reach SSO.
+	for i := 0; i < 5; i++ {
+		ctx := peer.NewContext(unknownToken.context(), &peer.Peer{Addr: addr})
+		want := codes.OK
+		if i >= 2 {
+			want = codes.ResourceExhausted
+		}
+		if got := runUnary(t, r.Interceptor, ctx, method, nil); got != want {
+			t.Errorf("call %d: got %v, want %v", i, got, want)
+		}
+	}
+	if identity.userInfoCalls != 2 {
+		t.Errorf("SSO asked %d times, want 2", identity.userInfoCalls)
+	}
+
+	// A real user behind the same peer is throttled with it until the
+	// token is cached.
+	if got := runUnary(t, r.Interceptor, peer.NewContext(customer.context(), &peer.Peer{Addr: addr}), method, nil); got != codes.ResourceExhausted {
+		t.Errorf("uncached customer: got %v", got)
+	}
+	identity.uncached = false
+	if got := runUnary(t, r.Interceptor, peer.NewContext(customer.context(), &peer.Peer{Addr: addr}), method, nil); got != codes.OK {
+		t.Errorf("cached customer: got %v", got)
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	r, now := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{RPS: 1, Burst: 1},
+	})
+
+	runUnary(t, r.Interceptor, customer.context(), "/order.OrderService/GetOrder", nil)
+	*now = now.Add(bucketIdleTTL + time.Second)
+	runUnary(t, r.Interceptor, admin.context(), "/order.OrderService/GetOrder", nil)
+
+	if len(r.buckets) != 1 {
+		t.Errorf("got %d buckets, want 1", len(r.buckets))
+	}
+}
@@ -0,0 +1,287 @@
+package httpapp
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+const shutdownTimeout = 10 * time.Second
+
+// listQueryParams maps REST query parameters of GET /v1/orders to the gRPC
+// metadata keys understood by the ListOrders handler.
+var listQueryParams = map[string]string{
+	"user_id": orderGrpc.MetadataListUserId,
+	"status":  orderGrpc.MetadataListStatus,
+	"limit":   orderGrpc.MetadataListLimit,
+	"offset":  orderGrpc.MetadataListOffset,
+}
+
+// App is a REST/JSON gateway in front of the gRPC server.
+type App struct {
+	log          *slog.Logger
+	httpServer   *http.Server
+	port         int
+	grpcEndpoint string
+	grpcCreds    credentials.TransportCredentials
+	conn         *grpc.ClientConn
+	// dialOptions are added when connecting to the gRPC server; tests use
+	// them to reach an in-memory one.
+	dialOptions []grpc.DialOption
+}
+
+func New(
+	log *slog.Logger,
+	port int,
+	grpcPort int,
+	grpcCreds credentials.TransportCredentials,
+) *App {
+	return &App{
+		log:          log,
+		port:         port,
+		grpcEndpoint: fmt.Sprintf("localhost:%d", grpcPort),
+		grpcCreds:    grpcCreds,
+		httpServer: &http.Server{
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// MustRun runs HTTP gateway and panics if any error occurs.
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Run runs HTTP gateway.
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	l, err := a.listen()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.serve(l)
+}
+
+// Start binds the listener and serves in the background. Errors that stop
+// serving later on are sent to errs.
+func (a *App) Start(errs chan<- error) error {
+	const op = "httpapp.Start"
+
+	l, err := a.listen()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go func() {
+		if err := a.serve(l); err != nil {
+			errs <- err
+		}
+	}()
+
+	return nil
+}
+
+func (a *App) listen() (net.Listener, error) {
+	conn, err := grpc.NewClient(a.grpcEndpoint, append(a.dialOptions, grpc.WithTransportCredentials(a.grpcCreds))...)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMetadata(listQueryMetadata),
+	)
+
+	if err := orderv20.RegisterOrderServiceHandler(context.Background(), mux, conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if err := mux.HandlePath(http.MethodPost, "/v1/orders/{id}/cancel", cancelOrderHandler(mux, orderGrpc.NewExtensionClient(conn))); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/orders/search", searchOrdersHandler(mux, orderGrpc.NewExtensionClient(conn))); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/openapi.json", serveOpenAPI); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	a.conn = conn
+	a.httpServer.Handler = mux
+
+	return l, nil
+}
+
+func (a *App) serve(l net.Listener) error {
+	const op = "httpapp.serve"
+
+	a.log.Info("http gateway started", slog.String("addr", l.Addr().String()))
+
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	_ = a.Shutdown(ctx)
+}
+
+// Shutdown stops accepting requests and waits for in-flight ones until ctx
+// is done, then closes the remaining connections.
+func (a *App) Shutdown(ctx context.Context) error {
+	const op = "httpapp.Shutdown"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping http gateway", slog.Int("port", a.port))
+
+	err := a.httpServer.Shutdown(ctx)
+	if err != nil {
+		_ = a.httpServer.Close()
+	}
+
+	if a.conn != nil {
+		_ = a.conn.Close()
+	}
+
+	return err
+}
+
+func listQueryMetadata(_ context.Context, r *http.Request) metadata.MD {
+	if r.Method != http.MethodGet || r.URL.Path != "/v1/orders" {
+		return nil
+	}
+
+	md := metadata.MD{}
+	query := r.URL.Query()
+	for param, key := range listQueryParams {
+		if v := query.Get(param); v != "" {
+			md.Set(key, v)
+		}
+	}
+
+	return md
+}
+
+func cancelOrderHandler(mux *runtime.ServeMux, client *orderGrpc.ExtensionClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, orderGrpc.CancelOrderMethod, runtime.WithHTTPPathPattern("/v1/orders/{id}/cancel"))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+
+		id, err := strconv.Atoi(pathParams["id"])
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, status.Error(codes.InvalidArgument, "id must be an integer"))
+			return
+		}
+
+		var md runtime.ServerMetadata
+		resp, err := client.CancelOrder(ctx, &orderv20.DeleteOrderRequest{Id: int32(id)}, grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, outboundMarshaler, w, r, resp)
+	}
+}
+
+// searchQueryParams maps REST query parameters of GET /v1/orders/search to
+// fields of the SearchOrders request.
+var searchQueryParams = map[string]string{
+	"q":       "query",
+	"user_id": "user_id",
+	"from":    "from",
+	"to":      "to",
+	"limit":   "limit",
+	"offset":  "offset",
+}
+
+func searchOrdersHandler(mux *runtime.ServeMux, client *orderGrpc.ExtensionClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, orderGrpc.SearchOrdersMethod, runtime.WithHTTPPathPattern("/v1/orders/search"))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+
+		// Numbers are sent as numbers; anything else stays a string so the
+		// server reports it as a field violation.
+		req := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		query := r.URL.Query()
+		for param, field := range searchQueryParams {
+			v := query.Get(param)
+			if v == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(v); err == nil && field != "query" {
+				req.Fields[field] = structpb.NewNumberValue(float64(n))
+			} else {
+				req.Fields[field] = structpb.NewStringValue(v)
+			}
+		}
+
+		var md runtime.ServerMetadata
+		resp, err := client.SearchOrders(ctx, req, grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, r, err)
+			return
+		}
+
+		if total := md.HeaderMD.Get(orderGrpc.MetadataTotalCount); len(total) > 0 {
+			w.Header().Set("X-Total-Count", total[0])
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, outboundMarshaler, w, r, resp)
+	}
+}
+
+func serveOpenAPI(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDocument)
+}
@@ -0,0 +1,228 @@
+package httpapp
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/fake"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOrders is the order service behind the gateway. Order 1 exists, order
+// 2 is already cancelled, any other is missing.
+type fakeOrders struct{}
+
+func (fakeOrders) CreateOrder(_ context.Context, o *domain.Order) (*domain.Order, error) {
+	return o, nil
+}
+
+func (fakeOrders) ListOrders(context.Context, models.OrderFilter) ([]*domain.Order, error) {
+	return []*domain.Order{domain.NewOrder(7, domain.Item{ID: 3})}, nil
+}
+
+func (fakeOrders) GetOrder(_ context.Context, id int) (*domain.Order, error) {
+	if id != 1 {
+		return nil, data.ErrRecordNotFound
+	}
+	return &domain.Order{ID: 1, UserId: 7, Lines: []domain.Line{{Item: domain.Item{ID: 3}, Quantity: 1}}}, nil
+}
+
+func (fakeOrders) GetOrdersByUserId(context.Context, int) ([]*domain.Order, error) {
+	return nil, nil
+}
+
+func (fakeOrders) CancelOrder(_ context.Context, id int) error {
+	switch id {
+	case 1:
+		return nil
+	case 2:
+		return data.ErrOrderCancelled
+	}
+	return data.ErrRecordNotFound
+}
+
+func (fakeOrders) SearchOrders(context.Context, models.SearchFilter) ([]*domain.Order, int, error) {
+	return nil, 12, nil
+}
+
+// backend records the method and incoming metadata of every call.
+type backend struct {
+	mu    sync.Mutex
+	calls []call
+}
+
+type call struct {
+	method string
+	md     metadata.MD
+}
+
+func (b *backend) record(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	b.mu.Lock()
+	b.calls = append(b.calls, call{method: info.FullMethod, md: md})
+	b.mu.Unlock()
+
+	return handler(ctx, req)
+}
+
+func (b *backend) last(t *testing.T) call {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.calls) == 0 {
+		t.Fatal("no gRPC call")
+	}
+	return b.calls[len(b.calls)-1]
+}
+
+// startGateway serves the gateway on a random port in front of an
+// in-memory gRPC server.
+func startGateway(t *testing.T) (*App, *backend, string) {
+	t.Helper()
+
+	b := &backend{}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(b.record))
+	orderGrpc.Register(server, fakeOrders{}, nil, nil, nil)
+	bufl := fake.Listen()
+	go func() { _ = server.Serve(bufl) }()
+	t.Cleanup(server.Stop)
+
+	a := New(slog.New(slog.NewTextHandler(io.Discard, nil)), 0, 0, insecure.NewCredentials())
+	a.grpcEndpoint = "passthrough:///bufconn"
+	a.dialOptions = []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return bufl.DialContext(ctx)
+	})}
+
+	l, err := a.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = a.serve(l) }()
+	t.Cleanup(a.Stop)
+
+	return a, b, "http://" + l.Addr().String()
+}
+
+func do(t *testing.T, method, url string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return resp
+}
+
+func TestGateway_Metadata(t *testing.T) {
+	_, b, base := startGateway(t)
+
+	resp := do(t, http.MethodGet, base+"/v1/orders?user_id=7&status=created&limit=5&offset=10&other=1", http.Header{"Authorization": {"t0ken"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", resp.Status)
+	}
+
+	got := b.last(t)
+	if got.method != "/order.OrderService/ListOrders" {
+		t.Fatalf("called %s", got.method)
+	}
+	want := map[string]string{
+		"authorization":              "t0ken",
+		orderGrpc.MetadataListUserId: "7",
+		orderGrpc.MetadataListStatus: "created",
+		orderGrpc.MetadataListLimit:  "5",
+		orderGrpc.MetadataListOffset: "10",
+	}
+	for key, value := range want {
+		if v := got.md.Get(key); len(v) != 1 || v[0] != value {
+			t.Errorf("%s: got %v, want %s", key, v, value)
+		}
+	}
+
+	// The list parameters only apply to listing.
+	do(t, http.MethodGet, base+"/v1/orders/1?user_id=7", nil)
+	if got := b.last(t); len(got.md.Get(orderGrpc.MetadataListUserId)) != 0 {
+		t.Errorf("GetOrder got list metadata %v", got.md)
+	}
+}
+
+func TestGateway_SearchRoute(t *testing.T) {
+	_, b, base := startGateway(t)
+
+	resp := do(t, http.MethodGet, base+"/v1/orders/search?q=bowl", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", resp.Status)
+	}
+	if got := b.last(t).method; got != orderGrpc.SearchOrdersMethod {
+		t.Errorf("called %s, want %s", got, orderGrpc.SearchOrdersMethod)
+	}
+	if got := resp.Header.Get("X-Total-Count"); got != "12" {
+		t.Errorf("X-Total-Count %q", got)
+	}
+
+	do(t, http.MethodGet, base+"/v1/orders/1", nil)
+	if got := b.last(t).method; got != "/order.OrderService/GetOrder" {
+		t.Errorf("called %s for an order id", got)
+	}
+}
+
+func TestGateway_Cancel(t *testing.T) {
+	_, b, base := startGateway(t)
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"1", http.StatusOK},
+		{"2", http.StatusBadRequest},
+		{"9", http.StatusNotFound},
+		{"x", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		resp := do(t, http.MethodPost, base+"/v1/orders/"+tt.id+"/cancel", http.Header{"Authorization": {"t0ken"}})
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %s, want %d", tt.id, resp.Status, tt.want)
+		}
+	}
+
+	got := b.last(t)
+	if got.method != orderGrpc.CancelOrderMethod || strings.Join(got.md.Get("authorization"), "") != "t0ken" {
+		t.Errorf("got %s with %v", got.method, got.md)
+	}
+}
+
+func TestGateway_Shutdown(t *testing.T) {
+	a, _, base := startGateway(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn, err := net.DialTimeout("tcp", strings.TrimPrefix(base, "http://"), time.Second); err == nil {
+		_ = conn.Close()
+		t.Error("listener still accepts connections")
+	}
+}
@@ -0,0 +1,89 @@
+package httpapp
+
+import (
+	"encoding/json"
+	catalogue "github.com/bxiit/protos/gen/go/catalogue"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+}
+
+// TestOpenAPI_Schemas checks that the documented schemas name exactly the
+// fields the gateway writes.
+func TestOpenAPI_Schemas(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	order := &orderv20.Order{Id: 1, ItemId: 2, UserId: 3, Item: &catalogue.Item{Id: 2, Name: "bowl"}}
+	tests := []struct {
+		schema string
+		msg    proto.Message
+	}{
+		{"OrderResponse", &orderv20.GetOrderResponse{Order: order}},
+		{"ListOrdersResponse", &orderv20.ListOrdersResponse{Orders: []*orderv20.Order{order}}},
+		{"CancelOrderResponse", &orderv20.DeleteOrderResponse{IsDeleted: true}},
+	}
+
+	_, marshaler := runtime.MarshalerForRequest(runtime.NewServeMux(), httptest.NewRequest("GET", "/", nil))
+	for _, tt := range tests {
+		b, err := marshaler.Marshal(tt.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		compare(t, tt.schema, doc.Components.Schemas, doc.Components.Schemas[tt.schema], got)
+	}
+}
+
+func compare(t *testing.T, path string, schemas map[string]*schema, s *schema, v interface{}) {
+	t.Helper()
+
+	if s.Ref != "" {
+		s = schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if got, want := keys(v), keys(s.Properties); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: gateway writes %v, schema documents %v", path, got, want)
+		}
+		for name, field := range v {
+			if sub, ok := s.Properties[name]; ok {
+				compare(t, path+"."+name, schemas, sub, field)
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			compare(t, path+"[]", schemas, s.Items, elem)
+		}
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
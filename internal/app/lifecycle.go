@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"log/slog"
+	"time"
+)
+
+type hook struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// lifecycle starts components in the order they were added and stops the
+// ones that started in reverse order.
+type lifecycle struct {
+	log     *slog.Logger
+	hooks   []hook
+	started []hook
+	errs    chan error
+	// shutdownTimeout bounds the rollback of a failed start.
+	shutdownTimeout time.Duration
+}
+
+func newLifecycle(log *slog.Logger, shutdownTimeout time.Duration) *lifecycle {
+	return &lifecycle{
+		log:             log,
+		errs:            make(chan error, 8),
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// append registers a component. Either function may be nil.
+func (l *lifecycle) append(name string, start, stop func(ctx context.Context) error) {
+	l.hooks = append(l.hooks, hook{name: name, start: start, stop: stop})
+}
+
+// Start runs every start hook in order. When one fails, the components that
+// already started are stopped again and the error is returned. The rollback
+// gets its own shutdown deadline, since a start that timed out has used up
+// ctx.
+func (l *lifecycle) Start(ctx context.Context) error {
+	const op = "app.Start"
+
+	for _, h := range l.hooks {
+		if h.start != nil {
+			if err := h.start(ctx); err != nil {
+				stopCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+				stopErr := l.Stop(stopCtx)
+				cancel()
+				return errors.Join(fmt.Errorf("%s: %s: %w", op, h.name, err), stopErr)
+			}
+		}
+		l.started = append(l.started, h)
+		l.log.Debug("component started", slog.String("component", h.name))
+	}
+
+	return nil
+}
+
+// Stop runs the stop hooks of the started components in reverse order. All
+// of them share ctx, so once its deadline passes the remaining components are
+// told to stop immediately.
+func (l *lifecycle) Stop(ctx context.Context) error {
+	const op = "app.Stop"
+
+	var errs []error
+	for i := len(l.started) - 1; i >= 0; i-- {
+		h := l.started[i]
+		if h.stop == nil {
+			continue
+		}
+
+		begin := time.Now()
+		if err := h.stop(ctx); err != nil {
+			l.log.Error("failed to stop component", slog.String("component", h.name), sl.Err(err))
+			errs = append(errs, fmt.Errorf("%s: %s: %w", op, h.name, err))
+			continue
+		}
+		l.log.Debug("component stopped", slog.String("component", h.name), slog.Duration("took", time.Since(begin)))
+	}
+	l.started = nil
+
+	return errors.Join(errs...)
+}
+
+// Errors reports components that failed after a successful start.
+func (l *lifecycle) Errors() <-chan error {
+	return l.errs
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestLifecycle_StartRollback checks that a start which used up its deadline
+// still stops the components that started before it, newest first.
+func TestLifecycle_StartRollback(t *testing.T) {
+	l := newLifecycle(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second)
+
+	var stopped []string
+	stop := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stopped = append(stopped, name)
+			return nil
+		}
+	}
+	l.append("storage", nil, stop("storage"))
+	l.append("server", nil, stop("server"))
+	l.append("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, stop("slow"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := l.Start(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the start deadline", err)
+	}
+	if len(stopped) != 2 || stopped[0] != "server" || stopped[1] != "storage" {
+		t.Errorf("stopped %v, want [server storage]; error: %v", stopped, err)
+	}
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/memory"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/seed"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	"github.com/bxiit/order-service-pet-store/internal/services/report"
+	"time"
+)
+
+// repository is what the services need from either storage driver.
+type repository interface {
+	order.OrderRepo
+	report.ReportRepo
+	importer.ImportRepo
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// memorySeed sizes the sample data the memory driver starts with.
+var memorySeed = seed.Options{Seed: 1, Users: 20, Items: 50, Orders: 200, Days: 30}
+
+// openStorage picks the storage driver. The returned *sql.DB is nil with
+// the memory driver.
+func openStorage(cfg *config.Config) (repository, *sql.DB, error) {
+	const op = "app.openStorage"
+
+	if cfg.StorageDriver == "memory" {
+		storage, err := newMemoryStorage(time.Now().UTC().Truncate(24 * time.Hour))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return storage, nil, nil
+	}
+
+	storage, err := data.New(cfg.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	storage.ReportViews = cfg.Reports.UseViews
+
+	return storage, storage.DB, nil
+}
+
+// newMemoryStorage creates a memory storage holding the same kind of sample
+// data orderctl seed puts in a database, with orders up to end.
+func newMemoryStorage(end time.Time) (*memory.Storage, error) {
+	opts := memorySeed
+	opts.End = end
+	ds := seed.Generate(opts)
+
+	storage := memory.New()
+	userIds := make([]int32, 0, len(ds.Users))
+	for _, email := range ds.Users {
+		userIds = append(userIds, storage.AddUser(email))
+	}
+	itemIds := make([]int32, 0, len(ds.Items))
+	for _, item := range ds.Items {
+		itemIds = append(itemIds, storage.AddItem(item))
+	}
+
+	orders := make([]*models.Order, 0, len(ds.Orders))
+	for _, o := range ds.Orders {
+		orders = append(orders, &models.Order{
+			UserId:    userIds[o.UserId-1],
+			ItemId:    itemIds[o.ItemId-1],
+			Status:    o.Status,
+			CreatedAt: o.CreatedAt,
+		})
+	}
+	if err := storage.ImportOrders(context.Background(), orders); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
@@ -0,0 +1,596 @@
+// Package datatest checks that an order repository behaves like the
+// PostgreSQL one. Every implementation runs the same suite from its own
+// tests:
+//
+//	func TestConformance(t *testing.T) {
+//		datatest.Run(t, newFixture)
+//	}
+package datatest
+
+import (
+	"context"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	"github.com/bxiit/order-service-pet-store/internal/services/report"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Repository is everything the services need from storage.
+type Repository interface {
+	order.OrderRepo
+	report.ReportRepo
+	SaveOrder(context.Context, *domain.Order) (*domain.Order, error)
+	importer.ImportRepo
+	ExportOrders(context.Context, models.ExportFilter, func(*dto.OrderDTO) error) error
+}
+
+// Fixture is an empty repository plus ways to create the users and items
+// orders refer to, which live outside the repository.
+type Fixture struct {
+	Repo    Repository
+	AddUser func(t *testing.T) int32
+	AddItem func(t *testing.T, item dto.ItemDTO) int32
+}
+
+// Run runs the suite. newFixture is called once per test and must return a
+// repository without any users, items or orders.
+func Run(t *testing.T, newFixture func(t *testing.T) Fixture) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, f Fixture)
+	}{
+		{"SaveOrder", testSaveOrder},
+		{"SaveOrderUnknownReferences", testSaveOrderUnknownReferences},
+		{"GetOrderById", testGetOrderById},
+		{"GetAllOrders", testGetAllOrders},
+		{"GetOrdersByUserId", testGetOrdersByUserId},
+		{"CancelOrder", testCancelOrder},
+		{"SaveOrderWithinQuota", testSaveOrderWithinQuota},
+		{"ImportOrders", testImportOrders},
+		{"ExistingUsersAndItems", testExisting},
+		{"SearchOrders", testSearchOrders},
+		{"ExportOrders", testExportOrders},
+		{"SalesByPeriod", testSalesByPeriod},
+		{"TopItemsAndUsers", testTopItemsAndUsers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newFixture(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+// day is a fixed UTC midnight the tests place orders around.
+var day = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+func item(name, description string, price int32) dto.ItemDTO {
+	return dto.ItemDTO{Name: name, Description: description, Price: price, Quantity: 10, ImageURL: "https://example.com/" + name}
+}
+
+// importOrders stores orders with the given user, item, status and
+// creation time and returns them with their ids.
+func importOrders(t *testing.T, f Fixture, orders ...models.Order) []models.Order {
+	t.Helper()
+
+	ptrs := make([]*models.Order, len(orders))
+	for i := range orders {
+		ptrs[i] = &orders[i]
+	}
+	if err := f.Repo.ImportOrders(ctx, ptrs); err != nil {
+		t.Fatalf("ImportOrders: %v", err)
+	}
+	return orders
+}
+
+func at(userId, itemId int32, status string, createdAt time.Time) models.Order {
+	return models.Order{UserId: userId, ItemId: itemId, Status: status, CreatedAt: createdAt}
+}
+
+func ids[T any](rows []T, id func(T) int32) []int32 {
+	out := make([]int32, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, id(r))
+	}
+	return out
+}
+
+func orderId(o *domain.Order) int32   { return o.ID }
+func importedId(o models.Order) int32 { return o.ID }
+
+// onlyItem returns the item of an order read back from storage, which must
+// hold a single line of one item.
+func onlyItem(t *testing.T, o *domain.Order) domain.Item {
+	t.Helper()
+	if len(o.Lines) != 1 || o.Lines[0].Quantity != 1 {
+		t.Fatalf("order %d has lines %+v, want one of one item", o.ID, o.Lines)
+	}
+	return o.Lines[0].Item
+}
+
+func equalIds(t *testing.T, what string, got, want []int32) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: got ids %v, want %v", what, got, want)
+	}
+}
+
+func testSaveOrder(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "a steel bowl", 500))
+
+	before := time.Now().Add(-time.Minute)
+	first, err := f.Repo.SaveOrder(ctx, domain.NewOrder(user, domain.Item{ID: itemId}))
+	if err != nil {
+		t.Fatalf("SaveOrder: %v", err)
+	}
+	second, err := f.Repo.SaveOrder(ctx, domain.NewOrder(user, domain.Item{ID: itemId}))
+	if err != nil {
+		t.Fatalf("SaveOrder: %v", err)
+	}
+
+	if first.ID <= 0 || second.ID <= first.ID {
+		t.Errorf("ids %d then %d, want positive and increasing", first.ID, second.ID)
+	}
+	if first.Status != models.StatusCreated {
+		t.Errorf("status %q, want %q", first.Status, models.StatusCreated)
+	}
+	if first.CreatedAt.Before(before) || first.CreatedAt.After(time.Now().Add(time.Minute)) {
+		t.Errorf("created_at %v is not about now", first.CreatedAt)
+	}
+	if got := onlyItem(t, first); got.ID != itemId || got.Name != "bowl" || got.Price != 500 || got.Description != "a steel bowl" {
+		t.Errorf("item %+v not filled in", got)
+	}
+	if first.Total() != 500 {
+		t.Errorf("total %d, want 500", first.Total())
+	}
+
+	stored, err := f.Repo.GetOrderById(ctx, int(first.ID))
+	if err != nil {
+		t.Fatalf("GetOrderById: %v", err)
+	}
+	if stored.UserId != user || onlyItem(t, stored) != onlyItem(t, first) || !stored.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("stored %+v, saved %+v", stored, first)
+	}
+
+	if _, err := f.Repo.SaveOrder(ctx, domain.NewOrder(user, domain.Item{ID: itemId}, domain.Item{ID: itemId})); !errors.Is(err, dto.ErrLines) {
+		t.Errorf("two lines: got %v, want ErrLines", err)
+	}
+}
+
+func testSaveOrderUnknownReferences(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+
+	if _, err := f.Repo.SaveOrder(ctx, domain.NewOrder(user, domain.Item{ID: itemId + 100})); err == nil {
+		t.Error("order of an unknown item saved")
+	}
+	if _, err := f.Repo.SaveOrder(ctx, domain.NewOrder(user+100, domain.Item{ID: itemId})); err == nil {
+		t.Error("order of an unknown user saved")
+	}
+
+	orders, err := f.Repo.GetAllOrders(ctx, models.OrderFilter{})
+	if err != nil {
+		t.Fatalf("GetAllOrders: %v", err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("got %d orders after failed saves", len(orders))
+	}
+}
+
+func testGetOrderById(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+	o := importOrders(t, f, at(user, itemId, models.StatusCancelled, day))[0]
+
+	got, err := f.Repo.GetOrderById(ctx, int(o.ID))
+	if err != nil {
+		t.Fatalf("GetOrderById: %v", err)
+	}
+	if got.ID != o.ID || got.UserId != user || got.Status != models.StatusCancelled || !got.Cancelled() || !got.CreatedAt.Equal(day) {
+		t.Errorf("got %+v, want %+v", got, o)
+	}
+	if item := onlyItem(t, got); item.ID != itemId || item.Name != "bowl" || item.Price != 500 {
+		t.Errorf("got item %+v", item)
+	}
+
+	if _, err := f.Repo.GetOrderById(ctx, int(o.ID)+1); !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("missing order: got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func testGetAllOrders(t *testing.T, f Fixture) {
+	alice, bob := f.AddUser(t), f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+	o := importOrders(t, f,
+		at(alice, itemId, models.StatusCreated, day),
+		at(bob, itemId, models.StatusCreated, day),
+		at(alice, itemId, models.StatusCancelled, day),
+		at(alice, itemId, models.StatusCreated, day),
+		at(bob, itemId, models.StatusCancelled, day),
+	)
+
+	tests := []struct {
+		name   string
+		filter models.OrderFilter
+		want   []int32
+	}{
+		{"all", models.OrderFilter{}, []int32{o[0].ID, o[1].ID, o[2].ID, o[3].ID, o[4].ID}},
+		{"user", models.OrderFilter{UserId: alice}, []int32{o[0].ID, o[2].ID, o[3].ID}},
+		{"status", models.OrderFilter{Status: models.StatusCancelled}, []int32{o[2].ID, o[4].ID}},
+		{"user and status", models.OrderFilter{UserId: bob, Status: models.StatusCreated}, []int32{o[1].ID}},
+		{"limit", models.OrderFilter{Limit: 2}, []int32{o[0].ID, o[1].ID}},
+		{"page", models.OrderFilter{Limit: 2, Offset: 2}, []int32{o[2].ID, o[3].ID}},
+		{"offset without limit", models.OrderFilter{Offset: 3}, []int32{o[3].ID, o[4].ID}},
+		{"past the end", models.OrderFilter{Limit: 2, Offset: 10}, nil},
+		{"no match", models.OrderFilter{UserId: bob + 100}, nil},
+	}
+	for _, tt := range tests {
+		got, err := f.Repo.GetAllOrders(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		equalIds(t, tt.name, ids(got, orderId), tt.want)
+		for _, order := range got {
+			if onlyItem(t, order).Name != "bowl" {
+				t.Errorf("%s: order %d has lines %+v", tt.name, order.ID, order.Lines)
+			}
+		}
+	}
+}
+
+func testGetOrdersByUserId(t *testing.T, f Fixture) {
+	alice, bob := f.AddUser(t), f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "steel", 500))
+	o := importOrders(t, f,
+		at(alice, itemId, models.StatusCreated, day),
+		at(bob, itemId, models.StatusCreated, day),
+		at(alice, itemId, models.StatusCancelled, day),
+	)
+
+	got, err := f.Repo.GetOrdersByUserId(ctx, int(alice))
+	if err != nil {
+		t.Fatalf("GetOrdersByUserId: %v", err)
+	}
+	gotIds := ids(got, orderId)
+	if len(gotIds) == 2 && gotIds[0] > gotIds[1] {
+		gotIds[0], gotIds[1] = gotIds[1], gotIds[0]
+	}
+	equalIds(t, "alice", gotIds, []int32{o[0].ID, o[2].ID})
+	for _, order := range got {
+		if item := onlyItem(t, order); item.ID != itemId || item.Name != "bowl" || item.Description != "steel" {
+			t.Errorf("order %d has item %+v", order.ID, item)
+		}
+	}
+
+	none, err := f.Repo.GetOrdersByUserId(ctx, int(bob)+100)
+	if err != nil || len(none) != 0 {
+		t.Errorf("unknown user: got %v, %v", none, err)
+	}
+}
+
+func testCancelOrder(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+	o := importOrders(t, f, at(user, itemId, models.StatusCreated, day))[0]
+
+	if err := f.Repo.CancelOrder(ctx, int(o.ID)); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	got, err := f.Repo.GetOrderById(ctx, int(o.ID))
+	if err != nil {
+		t.Fatalf("GetOrderById: %v", err)
+	}
+	if got.Status != models.StatusCancelled {
+		t.Errorf("status %q after cancelling", got.Status)
+	}
+
+	if err := f.Repo.CancelOrder(ctx, int(o.ID)); !errors.Is(err, data.ErrOrderCancelled) {
+		t.Errorf("cancelling twice: got %v, want ErrOrderCancelled", err)
+	}
+	if err := f.Repo.CancelOrder(ctx, int(o.ID)+1); !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("missing order: got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func testSaveOrderWithinQuota(t *testing.T, f Fixture) {
+	alice, bob := f.AddUser(t), f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+	quota := models.OrderQuota{CallerId: alice, Limit: 2, Day: day}
+
+	// The quota is the caller's, whoever the orders are for.
+	for _, user := range []int32{alice, bob} {
+		if _, err := f.Repo.SaveOrderWithinQuota(ctx, domain.NewOrder(user, domain.Item{ID: itemId}), quota); err != nil {
+			t.Fatalf("SaveOrderWithinQuota: %v", err)
+		}
+	}
+	if _, err := f.Repo.SaveOrderWithinQuota(ctx, domain.NewOrder(alice, domain.Item{ID: itemId}), quota); !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Fatalf("over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if orders, err := f.Repo.GetAllOrders(ctx, models.OrderFilter{}); err != nil || len(orders) != 2 {
+		t.Errorf("got %d orders, %v; the rejected one must not be saved", len(orders), err)
+	}
+
+	others := []models.OrderQuota{
+		{CallerId: bob, Limit: 2, Day: day},
+		{CallerId: alice, Limit: 2, Day: day.AddDate(0, 0, 1)},
+		{CallerId: alice, Limit: 3, Day: day},
+		{CallerId: alice},
+	}
+	for _, q := range others {
+		if _, err := f.Repo.SaveOrderWithinQuota(ctx, domain.NewOrder(alice, domain.Item{ID: itemId}), q); err != nil {
+			t.Errorf("%+v: %v", q, err)
+		}
+	}
+}
+
+func testImportOrders(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+
+	o := importOrders(t, f,
+		at(user, itemId, models.StatusCreated, day),
+		at(user, itemId, models.StatusCancelled, day.Add(time.Hour)),
+	)
+	if o[0].ID <= 0 || o[1].ID <= o[0].ID {
+		t.Errorf("ids %d then %d, want positive and increasing", o[0].ID, o[1].ID)
+	}
+
+	bad := []*models.Order{
+		{UserId: user, ItemId: itemId, Status: models.StatusCreated, CreatedAt: day},
+		{UserId: user + 100, ItemId: itemId, Status: models.StatusCreated, CreatedAt: day},
+	}
+	if err := f.Repo.ImportOrders(ctx, bad); !errors.Is(err, data.ErrRejected) {
+		t.Errorf("unknown user: got %v, want ErrRejected", err)
+	}
+	bad = []*models.Order{{UserId: user, ItemId: itemId + 100, Status: models.StatusCreated, CreatedAt: day}}
+	if err := f.Repo.ImportOrders(ctx, bad); !errors.Is(err, data.ErrRejected) {
+		t.Errorf("unknown item: got %v, want ErrRejected", err)
+	}
+
+	all, err := f.Repo.GetAllOrders(ctx, models.OrderFilter{})
+	if err != nil {
+		t.Fatalf("GetAllOrders: %v", err)
+	}
+	equalIds(t, "after a rejected batch", ids(all, orderId), ids(o, importedId))
+}
+
+func testExisting(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+
+	users, err := f.Repo.ExistingUsers(ctx, []int32{user, user + 1})
+	if err != nil {
+		t.Fatalf("ExistingUsers: %v", err)
+	}
+	if !users[user] || users[user+1] {
+		t.Errorf("ExistingUsers: got %v", users)
+	}
+
+	items, err := f.Repo.ExistingItems(ctx, []int32{itemId, itemId + 1})
+	if err != nil {
+		t.Fatalf("ExistingItems: %v", err)
+	}
+	if !items[itemId] || items[itemId+1] {
+		t.Errorf("ExistingItems: got %v", items)
+	}
+
+	if none, err := f.Repo.ExistingUsers(ctx, nil); err != nil || len(none) != 0 {
+		t.Errorf("no ids: got %v, %v", none, err)
+	}
+}
+
+func testSearchOrders(t *testing.T, f Fixture) {
+	alice, bob := f.AddUser(t), f.AddUser(t)
+	food := f.AddItem(t, item("Salmon Cat Food", "Grain free dinner for cats", 700))
+	toy := f.AddItem(t, item("Feather Toy", "Keeps cats busy, not food", 300))
+	leash := f.AddItem(t, item("Dog Leash", "Red nylon", 900))
+	o := importOrders(t, f,
+		at(alice, food, models.StatusCreated, day),
+		at(bob, toy, models.StatusCreated, day.Add(time.Hour)),
+		at(alice, leash, models.StatusCreated, day.Add(2*time.Hour)),
+		at(bob, food, models.StatusCancelled, day.Add(3*time.Hour)),
+	)
+
+	tests := []struct {
+		name      string
+		filter    models.SearchFilter
+		want      []int32
+		wantTotal int
+	}{
+		// Name matches outrank description matches; ties go newest first.
+		{"ranked", models.SearchFilter{Query: "food"}, []int32{o[3].ID, o[0].ID, o[1].ID}, 3},
+		{"all words", models.SearchFilter{Query: "salmon food"}, []int32{o[3].ID, o[0].ID}, 2},
+		{"case", models.SearchFilter{Query: "LEASH"}, []int32{o[2].ID}, 1},
+		{"negation", models.SearchFilter{Query: "cats -toy"}, []int32{o[3].ID, o[0].ID}, 2},
+		{"or", models.SearchFilter{Query: "leash or feather"}, nil, 2},
+		{"phrase", models.SearchFilter{Query: `"red nylon"`}, []int32{o[2].ID}, 1},
+		{"no match", models.SearchFilter{Query: "hamster"}, nil, 0},
+		{"user", models.SearchFilter{Query: "food", UserId: alice}, []int32{o[0].ID}, 1},
+		{"range", models.SearchFilter{Query: "food", From: day.Add(time.Hour), To: day.Add(3 * time.Hour)}, []int32{o[1].ID}, 1},
+		{"page", models.SearchFilter{Query: "food", Offset: 1, Limit: 1}, []int32{o[0].ID}, 3},
+		{"past the end", models.SearchFilter{Query: "food", Offset: 5, Limit: 10}, nil, 3},
+	}
+	for _, tt := range tests {
+		if tt.filter.Limit == 0 {
+			tt.filter.Limit = 20
+		}
+		got, total, err := f.Repo.SearchOrders(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total != tt.wantTotal {
+			t.Errorf("%s: total %d, want %d", tt.name, total, tt.wantTotal)
+		}
+		if tt.want != nil {
+			equalIds(t, tt.name, ids(got, orderId), tt.want)
+		}
+		for _, order := range got {
+			if item := onlyItem(t, order); item.ID == 0 || item.Name == "" {
+				t.Errorf("%s: order %d has item %+v", tt.name, order.ID, item)
+			}
+		}
+	}
+}
+
+func testExportOrders(t *testing.T, f Fixture) {
+	alice, bob := f.AddUser(t), f.AddUser(t)
+	itemId := f.AddItem(t, item("bowl", "", 500))
+	o := importOrders(t, f,
+		at(alice, itemId, models.StatusCreated, day),
+		at(bob, itemId, models.StatusCancelled, day.Add(time.Hour)),
+		at(alice, itemId, models.StatusCancelled, day.Add(2*time.Hour)),
+	)
+
+	export := func(filter models.ExportFilter) []int32 {
+		var got []int32
+		err := f.Repo.ExportOrders(ctx, filter, func(order *dto.OrderDTO) error {
+			if order.Item.Name != "bowl" {
+				t.Errorf("order %d has item %+v", order.ID, order.Item)
+			}
+			got = append(got, order.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ExportOrders: %v", err)
+		}
+		return got
+	}
+
+	equalIds(t, "all", export(models.ExportFilter{}), []int32{o[0].ID, o[1].ID, o[2].ID})
+	equalIds(t, "status", export(models.ExportFilter{Status: models.StatusCancelled}), []int32{o[1].ID, o[2].ID})
+	equalIds(t, "user", export(models.ExportFilter{UserId: alice}), []int32{o[0].ID, o[2].ID})
+	equalIds(t, "range", export(models.ExportFilter{From: day.Add(time.Hour), To: day.Add(2 * time.Hour)}), []int32{o[1].ID})
+
+	stop := errors.New("stop")
+	calls := 0
+	err := f.Repo.ExportOrders(ctx, models.ExportFilter{}, func(*dto.OrderDTO) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("callback error: got %v after %d calls", err, calls)
+	}
+}
+
+func testSalesByPeriod(t *testing.T, f Fixture) {
+	user := f.AddUser(t)
+	cheap := f.AddItem(t, item("bowl", "", 100))
+	dear := f.AddItem(t, item("bed", "", 1000))
+	importOrders(t, f,
+		// 2024-03-14 22:00 UTC is already the 15th in Almaty (UTC+5 then).
+		at(user, cheap, models.StatusCreated, day.Add(-2*time.Hour)),
+		at(user, dear, models.StatusCreated, day.Add(time.Hour)),
+		at(user, dear, models.StatusCancelled, day.Add(2*time.Hour)),
+		at(user, cheap, models.StatusCreated, day.AddDate(0, 0, 3)),
+		at(user, cheap, models.StatusCreated, day.AddDate(0, 1, 0)),
+	)
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	sales := func(period time.Time, orders, revenue int64) models.PeriodSales {
+		return models.PeriodSales{Period: period, Sales: models.Sales{Orders: orders, Revenue: revenue}}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.ReportFilter
+		want   []models.PeriodSales
+	}{
+		{"days", models.ReportFilter{Timezone: "UTC", Granularity: models.GranularityDay}, []models.PeriodSales{
+			sales(date(2024, 3, 14), 1, 100),
+			sales(date(2024, 3, 15), 1, 1000),
+			sales(date(2024, 3, 18), 1, 100),
+			sales(date(2024, 4, 15), 1, 100),
+		}},
+		{"days in a time zone", models.ReportFilter{Timezone: "Asia/Almaty", Granularity: models.GranularityDay, To: day.AddDate(0, 0, 1)}, []models.PeriodSales{
+			sales(date(2024, 3, 15), 2, 1100),
+		}},
+		{"weeks", models.ReportFilter{Timezone: "UTC", Granularity: models.GranularityWeek, To: day.AddDate(0, 0, 7)}, []models.PeriodSales{
+			sales(date(2024, 3, 11), 2, 1100),
+			sales(date(2024, 3, 18), 1, 100),
+		}},
+		{"months", models.ReportFilter{Timezone: "UTC", Granularity: models.GranularityMonth}, []models.PeriodSales{
+			sales(date(2024, 3, 1), 3, 1200),
+			sales(date(2024, 4, 1), 1, 100),
+		}},
+		{"range", models.ReportFilter{Timezone: "UTC", Granularity: models.GranularityDay, From: day, To: day.AddDate(0, 0, 1)}, []models.PeriodSales{
+			sales(date(2024, 3, 15), 1, 1000),
+		}},
+	}
+	for _, tt := range tests {
+		got, err := f.Repo.SalesByPeriod(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Period.Equal(tt.want[i].Period) || got[i].Sales != tt.want[i].Sales {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func testTopItemsAndUsers(t *testing.T, f Fixture) {
+	alice, bob, carol := f.AddUser(t), f.AddUser(t), f.AddUser(t)
+	cheap := f.AddItem(t, item("bowl", "", 100))
+	dear := f.AddItem(t, item("bed", "", 1000))
+	importOrders(t, f,
+		at(alice, cheap, models.StatusCreated, day),
+		at(alice, cheap, models.StatusCreated, day),
+		at(bob, cheap, models.StatusCreated, day),
+		at(bob, dear, models.StatusCreated, day),
+		at(carol, dear, models.StatusCancelled, day),
+	)
+
+	filter := models.ReportFilter{Timezone: "UTC", Limit: 10, By: models.ByQuantity}
+	items, err := f.Repo.TopItems(ctx, filter)
+	if err != nil {
+		t.Fatalf("TopItems: %v", err)
+	}
+	want := []models.ItemSales{
+		{ItemId: cheap, Name: "bowl", Sales: models.Sales{Orders: 3, Revenue: 300}},
+		{ItemId: dear, Name: "bed", Sales: models.Sales{Orders: 1, Revenue: 1000}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("by quantity: got %v, want %v", items, want)
+	}
+
+	filter.By, filter.Limit = models.ByRevenue, 1
+	items, err = f.Repo.TopItems(ctx, filter)
+	if err != nil {
+		t.Fatalf("TopItems: %v", err)
+	}
+	if !reflect.DeepEqual(items, want[1:]) {
+		t.Errorf("by revenue: got %v, want %v", items, want[1:])
+	}
+
+	filter.Limit = 10
+	users, err := f.Repo.SalesByUser(ctx, filter)
+	if err != nil {
+		t.Fatalf("SalesByUser: %v", err)
+	}
+	wantUsers := []models.UserSales{
+		{UserId: alice, Sales: models.Sales{Orders: 2, Revenue: 200}},
+		{UserId: bob, Sales: models.Sales{Orders: 2, Revenue: 1100}},
+	}
+	if !reflect.DeepEqual(users, wantUsers) {
+		t.Errorf("users: got %v, want %v", users, wantUsers)
+	}
+}
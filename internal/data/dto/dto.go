@@ -1,10 +1,14 @@
 package dto
 
+import "time"
+
 type OrderDTO struct {
-	ID     int32   `json:"id,omitempty"`
-	UserId int32   `json:"user_id"`
-	ItemId int32   `json:"item_id"`
-	Item   ItemDTO `json:"item"`
+	ID        int32     `json:"id,omitempty"`
+	UserId    int32     `json:"user_id"`
+	ItemId    int32     `json:"item_id"`
+	Status    string    `json:"status,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Item      ItemDTO   `json:"item"`
 }
 
 type ItemDTO struct {
@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+)
+
+// ErrLines is returned for orders storage can't hold: it keeps exactly one
+// line of quantity one per order.
+var ErrLines = errors.New("orders are stored with exactly one line of one item")
+
+// FromOrder builds the DTO of a stored order and its catalogue item.
+func FromOrder(order *models.Order, item ItemDTO) *OrderDTO {
+	return &OrderDTO{
+		ID:        order.ID,
+		UserId:    order.UserId,
+		ItemId:    order.ItemId,
+		Status:    order.Status,
+		CreatedAt: order.CreatedAt,
+		Item:      item,
+	}
+}
+
+// Order returns the stored form of o, dropping the item details.
+func (o *OrderDTO) Order() *models.Order {
+	return &models.Order{
+		ID:        o.ID,
+		UserId:    o.UserId,
+		ItemId:    o.ItemId,
+		Status:    o.Status,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+// Domain returns o as a single line domain order.
+func (o *OrderDTO) Domain() *domain.Order {
+	item := o.Item.Domain()
+	item.ID = o.ItemId
+
+	return &domain.Order{
+		ID:        o.ID,
+		UserId:    o.UserId,
+		Status:    o.Status,
+		Lines:     []domain.Line{{Item: item, Quantity: 1}},
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+// FromDomain builds the row of a domain order. Rows hold one item, so
+// orders with any other number of lines are refused with ErrLines.
+func FromDomain(order *domain.Order) (*OrderDTO, error) {
+	if len(order.Lines) != 1 || order.Lines[0].Quantity != 1 {
+		return nil, ErrLines
+	}
+	item := FromDomainItem(order.Lines[0].Item)
+
+	return &OrderDTO{
+		ID:        order.ID,
+		UserId:    order.UserId,
+		ItemId:    item.ID,
+		Status:    order.Status,
+		CreatedAt: order.CreatedAt,
+		Item:      item,
+	}, nil
+}
+
+// Domain returns the domain form of a catalogue item row.
+func (i ItemDTO) Domain() domain.Item {
+	return domain.Item{
+		ID:          i.ID,
+		Name:        i.Name,
+		Description: i.Description,
+		ImageURL:    i.ImageURL,
+		Price:       i.Price,
+		Quantity:    i.Quantity,
+	}
+}
+
+// FromDomainItem builds the row of a catalogue item.
+func FromDomainItem(item domain.Item) ItemDTO {
+	return ItemDTO{
+		ID:          item.ID,
+		Name:        item.Name,
+		Price:       item.Price,
+		Description: item.Description,
+		Quantity:    item.Quantity,
+		ImageURL:    item.ImageURL,
+	}
+}
@@ -0,0 +1,66 @@
+package dto_test
+
+import (
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestOrderRoundTrip(t *testing.T) {
+	order := &models.Order{
+		ID:        9,
+		UserId:    2,
+		ItemId:    3,
+		Status:    models.StatusCancelled,
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	item := dto.ItemDTO{ID: 3, Name: "bowl", Price: 450}
+
+	d := dto.FromOrder(order, item)
+	if d.Item != item {
+		t.Errorf("got item %+v, want %+v", d.Item, item)
+	}
+	if got := d.Order(); *got != *order {
+		t.Errorf("got %+v, want %+v", *got, *order)
+	}
+}
+
+func TestDomainRoundTrip(t *testing.T) {
+	row := &dto.OrderDTO{
+		ID:        9,
+		UserId:    2,
+		ItemId:    3,
+		Status:    models.StatusCreated,
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		Item:      dto.ItemDTO{ID: 3, Name: "bowl", Price: 450, Description: "steel", Quantity: 10, ImageURL: "https://img/bowl.png"},
+	}
+
+	order := row.Domain()
+	if order.Total() != 450 || len(order.Lines) != 1 || order.Lines[0].Item.Quantity != 10 {
+		t.Errorf("got %+v", order)
+	}
+
+	got, err := dto.FromDomain(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *row {
+		t.Errorf("got %+v, want %+v", *got, *row)
+	}
+}
+
+func TestFromDomain_Lines(t *testing.T) {
+	orders := []*domain.Order{
+		domain.NewOrder(2),
+		domain.NewOrder(2, domain.Item{ID: 3}, domain.Item{ID: 4}),
+		{UserId: 2, Lines: []domain.Line{{Item: domain.Item{ID: 3}, Quantity: 2}}},
+	}
+	for _, o := range orders {
+		if _, err := dto.FromDomain(o); !errors.Is(err, dto.ErrLines) {
+			t.Errorf("%+v: got %v, want ErrLines", o.Lines, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+)
+
+// exportBatchSize is how many rows ExportOrders fetches from its cursor at a
+// time, bounding memory whatever the size of the export.
+const exportBatchSize = 1000
+
+// ExportOrders streams the orders matching the filter to fn in id order. Rows
+// are read through a server-side cursor inside a read-only transaction, so
+// the export sees one consistent snapshot. An error returned by fn stops the
+// export and is returned as is.
+func (os *OrderStorage) ExportOrders(ctx context.Context, filter models.ExportFilter, fn func(*dto.OrderDTO) error) error {
+	const op = "data.ExportOrders"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	tx, err := os.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	query := `
+			DECLARE export_orders NO SCROLL CURSOR FOR
+			SELECT o.id, o.user_id, o.item_id, o.status, o.created_at,
+			       i.id, i.name, i.price, i.description, i.quantity, i.image_url
+			FROM order_service.orders o
+			INNER JOIN catalogue.item_info i
+			ON o.item_id = i.id
+			WHERE ($1::timestamptz IS NULL OR o.created_at >= $1)
+			  AND ($2::timestamptz IS NULL OR o.created_at < $2)
+			  AND ($3 = '' OR o.status = $3)
+			  AND ($4 = 0 OR o.user_id = $4)
+			ORDER BY o.id`
+	args := []interface{}{
+		sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()},
+		sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()},
+		filter.Status,
+		filter.UserId,
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fail(err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_orders", exportBatchSize)
+	for {
+		n, err := exportBatch(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+// exportBatch fetches the next batch from the cursor, hands its rows to fn
+// and returns how many there were.
+func exportBatch(ctx context.Context, tx *sql.Tx, fetch string, fn func(*dto.OrderDTO) error) (int, error) {
+	const op = "data.ExportOrders"
+
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var order dto.OrderDTO
+		err := rows.Scan(
+			&order.ID,
+			&order.UserId,
+			&order.ItemId,
+			&order.Status,
+			&order.CreatedAt,
+			&order.Item.ID,
+			&order.Item.Name,
+			&order.Item.Price,
+			&order.Item.Description,
+			&order.Item.Quantity,
+			&order.Item.ImageURL,
+		)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", op, err)
+		}
+		n++
+
+		if err := fn(&order); err != nil {
+			return n, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// ErrRejected marks an import the database refused because of its data, such
+// as a row violating a constraint, rather than because it was unreachable.
+var ErrRejected = errors.New("rejected by the database")
+
+// ExistingUsers reports which of the given user ids exist in sso.users.
+func (os *OrderStorage) ExistingUsers(ctx context.Context, ids []int32) (map[int32]bool, error) {
+	const op = "data.ExistingUsers"
+
+	existing, err := os.existingIds(ctx, `SELECT id FROM sso.users WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return existing, nil
+}
+
+// ExistingItems reports which of the given item ids exist in
+// catalogue.item_info.
+func (os *OrderStorage) ExistingItems(ctx context.Context, ids []int32) (map[int32]bool, error) {
+	const op = "data.ExistingItems"
+
+	existing, err := os.existingIds(ctx, `SELECT id FROM catalogue.item_info WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return existing, nil
+}
+
+func (os *OrderStorage) existingIds(ctx context.Context, query string, ids []int32) (map[int32]bool, error) {
+	existing := make(map[int32]bool, len(ids))
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	rows, err := os.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+
+	return existing, rows.Err()
+}
+
+// ImportOrders inserts the orders with their own status and creation time
+// using a single multi-row insert in a transaction: either every order is
+// stored or none is. The generated ids are written back to the orders.
+// Integrity and data errors are reported as ErrRejected.
+func (os *OrderStorage) ImportOrders(ctx context.Context, orders []*models.Order) error {
+	const op = "data.ImportOrders"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	if len(orders) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO order_service.orders (user_id, item_id, status, created_at) VALUES `)
+	args := make([]interface{}, 0, 4*len(orders))
+	for i, order := range orders {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4)
+		args = append(args, order.UserId, order.ItemId, order.Status, order.CreatedAt)
+	}
+	query.WriteString(` RETURNING id`)
+
+	tx, err := os.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && (pqErr.Code.Class() == "22" || pqErr.Code.Class() == "23") {
+			return fmt.Errorf("%s: %w: %v", op, ErrRejected, err)
+		}
+		return fail(err)
+	}
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(&orders[i].ID); err != nil {
+			rows.Close()
+			return fail(err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+// Package memory keeps orders, and the users and items they refer to, in
+// process memory. It behaves like data.OrderStorage, down to the errors it
+// returns, so the service can run and be tested without PostgreSQL.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Storage is safe for concurrent use. The zero value is not usable; call
+// New.
+type Storage struct {
+	mu sync.RWMutex
+
+	users map[int32]string
+	items map[int32]dto.ItemDTO
+	// orders is sorted by id, as ids only grow.
+	orders []models.Order
+	index  map[int32]int
+
+	lastUser  int32
+	lastItem  int32
+	lastOrder int32
+
+	// quota counts the orders of each caller per UTC day.
+	quota map[quotaKey]int
+
+	now func() time.Time
+}
+
+func New() *Storage {
+	return &Storage{
+		users: make(map[int32]string),
+		items: make(map[int32]dto.ItemDTO),
+		index: make(map[int32]int),
+		quota: make(map[quotaKey]int),
+		now:   time.Now,
+	}
+}
+
+// AddUser stands in for a row of sso.users and returns its id.
+func (s *Storage) AddUser(email string) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUser++
+	s.users[s.lastUser] = email
+
+	return s.lastUser
+}
+
+// AddItem stands in for a row of catalogue.item_info and returns its id.
+// The id of item is ignored.
+func (s *Storage) AddItem(item dto.ItemDTO) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastItem++
+	item.ID = s.lastItem
+	s.items[s.lastItem] = item
+
+	return s.lastItem
+}
+
+func (s *Storage) Ping(context.Context) error {
+	return nil
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+type quotaKey struct {
+	caller int32
+	day    string
+}
+
+func (s *Storage) SaveOrder(ctx context.Context, o *domain.Order) (*domain.Order, error) {
+	return s.SaveOrderWithinQuota(ctx, o, models.OrderQuota{})
+}
+
+// SaveOrderWithinQuota saves the order unless the caller has used up the
+// quota for the day, failing with data.ErrQuotaExceeded then.
+func (s *Storage) SaveOrderWithinQuota(ctx context.Context, o *domain.Order, quota models.OrderQuota) (*domain.Order, error) {
+	const op = "memory.SaveOrder"
+
+	row, err := dto.FromDomain(o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := quotaKey{caller: quota.CallerId, day: quota.Day.Format(time.DateOnly)}
+	if quota.Limit > 0 && s.quota[key] >= quota.Limit {
+		return nil, fmt.Errorf("%s: %w", op, data.ErrQuotaExceeded)
+	}
+
+	order := row.Order()
+	order.Status = models.StatusCreated
+	order.CreatedAt = s.timestamp()
+	if err := s.insert(order); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if quota.Limit > 0 {
+		s.quota[key]++
+	}
+
+	return s.domain(*order), nil
+}
+
+func (s *Storage) GetOrderById(ctx context.Context, id int) (*domain.Order, error) {
+	const op = "memory.GetOrderById"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.index[int32(id)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, data.ErrRecordNotFound)
+	}
+
+	return s.domain(s.orders[i]), nil
+}
+
+func (s *Storage) GetAllOrders(ctx context.Context, filter models.OrderFilter) ([]*domain.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []*domain.Order
+	skipped := 0
+	for _, order := range s.orders {
+		if filter.UserId != 0 && order.UserId != filter.UserId {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		if filter.Limit > 0 && len(orders) == filter.Limit {
+			break
+		}
+
+		orders = append(orders, s.domain(order))
+	}
+
+	return orders, nil
+}
+
+func (s *Storage) GetOrdersByUserId(ctx context.Context, userId int) ([]*domain.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []*domain.Order
+	for _, order := range s.orders {
+		if order.UserId == int32(userId) {
+			orders = append(orders, s.domain(order))
+		}
+	}
+
+	return orders, nil
+}
+
+func (s *Storage) CancelOrder(ctx context.Context, id int) error {
+	const op = "memory.CancelOrder"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.index[int32(id)]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, data.ErrRecordNotFound)
+	}
+	if s.orders[i].Status == models.StatusCancelled {
+		return fmt.Errorf("%s: %w", op, data.ErrOrderCancelled)
+	}
+	s.orders[i].Status = models.StatusCancelled
+
+	return nil
+}
+
+// ExportOrders hands the orders matching the filter to fn in id order. It
+// works on a copy taken up front, like the snapshot of the SQL export.
+func (s *Storage) ExportOrders(ctx context.Context, filter models.ExportFilter, fn func(*dto.OrderDTO) error) error {
+	s.mu.RLock()
+	var orders []*dto.OrderDTO
+	for _, order := range s.orders {
+		if !filter.From.IsZero() && order.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !order.CreatedAt.Before(filter.To) {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if filter.UserId != 0 && order.UserId != filter.UserId {
+			continue
+		}
+		orders = append(orders, s.withItem(order))
+	}
+	s.mu.RUnlock()
+
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExistingUsers reports which of the given user ids exist.
+func (s *Storage) ExistingUsers(ctx context.Context, ids []int32) (map[int32]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	existing := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := s.users[id]; ok {
+			existing[id] = true
+		}
+	}
+
+	return existing, nil
+}
+
+// ExistingItems reports which of the given item ids exist.
+func (s *Storage) ExistingItems(ctx context.Context, ids []int32) (map[int32]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	existing := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := s.items[id]; ok {
+			existing[id] = true
+		}
+	}
+
+	return existing, nil
+}
+
+// ImportOrders stores every order or, if one refers to a missing user or
+// item, none, failing with data.ErrRejected. The generated ids are written
+// back to the orders.
+func (s *Storage) ImportOrders(ctx context.Context, orders []*models.Order) error {
+	const op = "memory.ImportOrders"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, order := range orders {
+		if err := s.check(order); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	for _, order := range orders {
+		order.CreatedAt = order.CreatedAt.Truncate(time.Microsecond)
+		_ = s.insert(order)
+	}
+
+	return nil
+}
+
+// check enforces the foreign keys of an order.
+func (s *Storage) check(order *models.Order) error {
+	if _, ok := s.users[order.UserId]; !ok {
+		return fmt.Errorf("%w: user %d does not exist", data.ErrRejected, order.UserId)
+	}
+	if _, ok := s.items[order.ItemId]; !ok {
+		return fmt.Errorf("%w: item %d does not exist", data.ErrRejected, order.ItemId)
+	}
+	return nil
+}
+
+// insert checks and stores the order under the next id. Callers hold mu.
+func (s *Storage) insert(order *models.Order) error {
+	if err := s.check(order); err != nil {
+		return err
+	}
+
+	s.lastOrder++
+	order.ID = s.lastOrder
+	s.index[order.ID] = len(s.orders)
+	s.orders = append(s.orders, *order)
+
+	return nil
+}
+
+// timestamp is the current time at the precision PostgreSQL keeps.
+func (s *Storage) timestamp() time.Time {
+	return s.now().Truncate(time.Microsecond)
+}
+
+func (s *Storage) withItem(order models.Order) *dto.OrderDTO {
+	return dto.FromOrder(&order, s.items[order.ItemId])
+}
+
+func (s *Storage) domain(order models.Order) *domain.Order {
+	return s.withItem(order).Domain()
+}
+
+// sortedIds returns the keys of m in ascending order.
+func sortedIds[V any](m map[int32]V) []int32 {
+	ids := make([]int32, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
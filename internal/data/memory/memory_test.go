@@ -0,0 +1,66 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/datatest"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/memory"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"sync"
+	"testing"
+)
+
+func newFixture(t *testing.T) datatest.Fixture {
+	s := memory.New()
+	users := 0
+
+	return datatest.Fixture{
+		Repo: s,
+		AddUser: func(t *testing.T) int32 {
+			users++
+			return s.AddUser(fmt.Sprintf("user%d@example.com", users))
+		},
+		AddItem: func(t *testing.T, item dto.ItemDTO) int32 {
+			return s.AddItem(item)
+		},
+	}
+}
+
+func TestConformance(t *testing.T) {
+	datatest.Run(t, newFixture)
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	f := newFixture(t)
+	user := f.AddUser(t)
+	item := f.AddItem(t, dto.ItemDTO{Name: "bowl"})
+
+	var wg sync.WaitGroup
+	ids := make(chan int32, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := f.Repo.SaveOrder(context.Background(), domain.NewOrder(user, domain.Item{ID: item}))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- order.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int32]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("id %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("got %d distinct ids, want 100", len(seen))
+	}
+}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"sort"
+	"time"
+)
+
+// sales calls fn for every order a report counts: not cancelled, of an
+// existing item and created in the filter range. Callers hold mu.
+func (s *Storage) sales(filter models.ReportFilter, fn func(order models.Order, price int64)) {
+	for _, order := range s.orders {
+		if order.Status == models.StatusCancelled {
+			continue
+		}
+		if !filter.From.IsZero() && order.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !order.CreatedAt.Before(filter.To) {
+			continue
+		}
+		item, ok := s.items[order.ItemId]
+		if !ok {
+			continue
+		}
+		fn(order, int64(item.Price))
+	}
+}
+
+// SalesByPeriod totals orders and revenue per day, week or month. Periods
+// start at midnight in filter.Timezone and, like a PostgreSQL timestamp
+// without time zone, carry that wall-clock time in UTC.
+func (s *Storage) SalesByPeriod(ctx context.Context, filter models.ReportFilter) ([]models.PeriodSales, error) {
+	const op = "memory.SalesByPeriod"
+
+	loc, err := time.LoadLocation(filter.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[time.Time]*models.Sales)
+	var periods []time.Time
+	var truncErr error
+	s.sales(filter, func(order models.Order, price int64) {
+		period, err := truncate(order.CreatedAt.In(loc), filter.Granularity)
+		if err != nil {
+			truncErr = err
+			return
+		}
+		sales, ok := totals[period]
+		if !ok {
+			sales = &models.Sales{}
+			totals[period] = sales
+			periods = append(periods, period)
+		}
+		sales.Orders++
+		sales.Revenue += price
+	})
+	if truncErr != nil {
+		return nil, fmt.Errorf("%s: %w", op, truncErr)
+	}
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	var result []models.PeriodSales
+	for _, period := range periods {
+		result = append(result, models.PeriodSales{Period: period, Sales: *totals[period]})
+	}
+
+	return result, nil
+}
+
+// truncate cuts a local time down to the start of its period, like
+// date_trunc: weeks start on Monday.
+func truncate(t time.Time, granularity string) (time.Time, error) {
+	year, month, day := t.Date()
+	switch granularity {
+	case models.GranularityDay:
+	case models.GranularityWeek:
+		day -= (int(t.Weekday()) + 6) % 7
+	case models.GranularityMonth:
+		day = 1
+	default:
+		return time.Time{}, fmt.Errorf("unknown granularity %q", granularity)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// TopItems returns the filter.Limit best selling items by quantity or by
+// revenue.
+func (s *Storage) TopItems(ctx context.Context, filter models.ReportFilter) ([]models.ItemSales, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[int32]*models.ItemSales)
+	s.sales(filter, func(order models.Order, price int64) {
+		sales, ok := totals[order.ItemId]
+		if !ok {
+			sales = &models.ItemSales{ItemId: order.ItemId, Name: s.items[order.ItemId].Name}
+			totals[order.ItemId] = sales
+		}
+		sales.Orders++
+		sales.Revenue += price
+	})
+
+	items := make([]models.ItemSales, 0, len(totals))
+	for _, id := range sortedIds(totals) {
+		items = append(items, *totals[id])
+	}
+	key := func(s models.ItemSales) int64 {
+		if filter.By == models.ByRevenue {
+			return s.Revenue
+		}
+		return s.Orders
+	}
+	sort.SliceStable(items, func(i, j int) bool { return key(items[i]) > key(items[j]) })
+
+	return limit(items, filter.Limit), nil
+}
+
+// SalesByUser returns the filter.Limit users with the most orders.
+func (s *Storage) SalesByUser(ctx context.Context, filter models.ReportFilter) ([]models.UserSales, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totals := make(map[int32]*models.UserSales)
+	s.sales(filter, func(order models.Order, price int64) {
+		sales, ok := totals[order.UserId]
+		if !ok {
+			sales = &models.UserSales{UserId: order.UserId}
+			totals[order.UserId] = sales
+		}
+		sales.Orders++
+		sales.Revenue += price
+	})
+
+	users := make([]models.UserSales, 0, len(totals))
+	for _, id := range sortedIds(totals) {
+		users = append(users, *totals[id])
+	}
+	sort.SliceStable(users, func(i, j int) bool { return users[i].Orders > users[j].Orders })
+
+	return limit(users, filter.Limit), nil
+}
+
+// RefreshReportViews does nothing: reports are always computed from the
+// orders themselves.
+func (s *Storage) RefreshReportViews(context.Context) error {
+	return nil
+}
+
+func limit[T any](rows []T, n int) []T {
+	if n < 0 {
+		n = 0
+	}
+	if len(rows) > n {
+		rows = rows[:n]
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows
+}
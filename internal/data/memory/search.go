@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchOrders mimics the full-text search of data.OrderStorage: the query
+// follows websearch_to_tsquery syntax (words are ANDed, "or" separates
+// alternatives, a leading "-" negates, quotes make a phrase) and is matched
+// word by word against item names and descriptions, names weighing more.
+// Ranking is close to, not identical with, ts_rank.
+func (s *Storage) SearchOrders(ctx context.Context, filter models.SearchFilter) ([]*domain.Order, int, error) {
+	query := parseQuery(filter.Query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type hit struct {
+		order *domain.Order
+		rank  float64
+	}
+	var hits []hit
+	for _, order := range s.orders {
+		if filter.UserId != 0 && order.UserId != filter.UserId {
+			continue
+		}
+		if !filter.From.IsZero() && order.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !order.CreatedAt.Before(filter.To) {
+			continue
+		}
+		item, ok := s.items[order.ItemId]
+		if !ok {
+			continue
+		}
+
+		doc := newDocument(item)
+		if !query.matches(doc) {
+			continue
+		}
+		hits = append(hits, hit{order: s.domain(order), rank: query.rank(doc)})
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank > hits[j].rank
+		}
+		return hits[i].order.ID > hits[j].order.ID
+	})
+
+	total := len(hits)
+	if filter.Offset >= len(hits) || filter.Limit <= 0 {
+		return nil, total, nil
+	}
+	hits = hits[filter.Offset:min(filter.Offset+filter.Limit, len(hits))]
+
+	orders := make([]*domain.Order, 0, len(hits))
+	for _, h := range hits {
+		orders = append(orders, h.order)
+	}
+
+	return orders, total, nil
+}
+
+// Weights of name and description words, as ts_rank weighs A and B.
+const (
+	nameWeight        = 1.0
+	descriptionWeight = 0.4
+)
+
+// document is an item as the search sees it: the words of its name followed
+// by those of its description.
+type document struct {
+	words     []string
+	nameWords int
+}
+
+func newDocument(item dto.ItemDTO) document {
+	name := words(item.Name)
+	return document{
+		words:     append(name, words(item.Description)...),
+		nameWords: len(name),
+	}
+}
+
+// words lower-cases text and splits it into runs of letters and digits, as
+// the 'simple' text search configuration does for plain words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// phrase is a sequence of words that must appear in a row, or must not when
+// negated. A single word is a phrase of one.
+type phrase struct {
+	words   []string
+	negated bool
+}
+
+// query is a disjunction of conjunctions of phrases.
+type query [][]phrase
+
+func parseQuery(text string) query {
+	var q query
+	var current []phrase
+
+	for rest := strings.TrimSpace(text); rest != ""; rest = strings.TrimSpace(rest) {
+		negated := false
+		if strings.HasPrefix(rest, "-") {
+			negated = true
+			rest = rest[1:]
+		}
+
+		var token string
+		quoted := strings.HasPrefix(rest, `"`)
+		if quoted {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				token, rest = rest[1:], ""
+			} else {
+				token, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			token, rest = rest[:end], rest[end:]
+		}
+
+		if !quoted && !negated && strings.EqualFold(token, "or") {
+			if len(current) > 0 {
+				q = append(q, current)
+				current = nil
+			}
+			continue
+		}
+
+		if w := words(token); len(w) > 0 {
+			if quoted {
+				current = append(current, phrase{words: w, negated: negated})
+			} else {
+				// Unquoted punctuation splits a token into ANDed words.
+				for i, word := range w {
+					current = append(current, phrase{words: []string{word}, negated: negated && i == 0})
+				}
+			}
+		}
+	}
+	if len(current) > 0 {
+		q = append(q, current)
+	}
+
+	return q
+}
+
+func (q query) matches(doc document) bool {
+	for _, all := range q {
+		if matchesAll(all, doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(phrases []phrase, doc document) bool {
+	positive := false
+	for _, p := range phrases {
+		found := len(doc.find(p.words)) > 0
+		if found == p.negated {
+			return false
+		}
+		positive = positive || !p.negated
+	}
+	// A purely negative query matches nothing, as a tsquery of only
+	// negations never selects rows through the index condition.
+	return positive
+}
+
+func (q query) rank(doc document) float64 {
+	rank := 0.0
+	for _, all := range q {
+		for _, p := range all {
+			if p.negated {
+				continue
+			}
+			for _, at := range doc.find(p.words) {
+				if at < doc.nameWords {
+					rank += nameWeight
+				} else {
+					rank += descriptionWeight
+				}
+			}
+		}
+	}
+	return rank
+}
+
+// find returns every position where seq starts in the document.
+func (d document) find(seq []string) []int {
+	var at []int
+	for i := 0; i+len(seq) <= len(d.words); i++ {
+		match := true
+		for j, word := range seq {
+			if d.words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			at = append(at, i)
+		}
+	}
+	return at
+}
@@ -1,7 +1,111 @@
 package models
 
+import (
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"time"
+)
+
+// Stored order statuses, the domain ones.
+const (
+	StatusCreated   = domain.StatusCreated
+	StatusCancelled = domain.StatusCancelled
+)
+
 type Order struct {
-	ID     int32 `json:"id,omitempty"`
-	UserId int32 `json:"user_id"`
-	ItemId int32 `json:"item_id"`
+	ID        int32     `json:"id,omitempty"`
+	UserId    int32     `json:"user_id"`
+	ItemId    int32     `json:"item_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrderFilter narrows down the orders returned by a listing. Zero values mean
+// "no restriction"; a zero Limit returns every matching order.
+type OrderFilter struct {
+	UserId int32
+	Status string
+	Limit  int
+	Offset int
+}
+
+// OrderQuota caps how many orders one caller may create on the UTC day Day.
+// A zero Limit means no cap.
+type OrderQuota struct {
+	CallerId int32
+	Limit    int
+	Day      time.Time
+}
+
+// SearchFilter is a free-text search over the name and description of ordered
+// items, optionally narrowed to a user and a creation time range [From, To).
+type SearchFilter struct {
+	Query  string
+	UserId int32
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// ExportFilter selects the orders an export streams: those created in
+// [From, To), optionally of one status and user. Zero values mean "no
+// restriction".
+type ExportFilter struct {
+	From   time.Time
+	To     time.Time
+	Status string
+	UserId int32
+}
+
+// ReportFilter selects the orders a report aggregates: those created in
+// [From, To), zero meaning unbounded. Periods are cut in Timezone.
+type ReportFilter struct {
+	From        time.Time
+	To          time.Time
+	Timezone    string
+	Granularity string // day, week or month
+	Limit       int
+	By          string // quantity or revenue, for top items
+}
+
+// Report granularities and top-item orderings.
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+
+	ByQuantity = "quantity"
+	ByRevenue  = "revenue"
+)
+
+// Sales is what a group of orders brought in. Cancelled orders don't count
+// and revenue uses the current item price, as orders don't record the price
+// paid.
+type Sales struct {
+	Orders  int64
+	Revenue int64
+}
+
+// AverageOrderValue is Revenue / Orders, or zero without orders.
+func (s Sales) AverageOrderValue() float64 {
+	if s.Orders == 0 {
+		return 0
+	}
+	return float64(s.Revenue) / float64(s.Orders)
+}
+
+type PeriodSales struct {
+	Period time.Time
+	Sales
+}
+
+type ItemSales struct {
+	ItemId int32
+	Name   string
+	Sales
+}
+
+type UserSales struct {
+	UserId int32
+	Sales
 }
@@ -7,26 +7,46 @@ import (
 	"fmt"
 	"github.com/bxiit/order-service-pet-store/internal/data/dto"
 	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
 	"github.com/lib/pq"
 	"strings"
+	"time"
 )
 
 type OrderStorage struct {
 	DB *sql.DB
+	// ReportViews lets reports read the materialized views where they
+	// give exact answers.
+	ReportViews bool
 }
 
 var (
 	ErrRecordNotFound = errors.New("record (row, entry) not found")
+	ErrOrderCancelled = errors.New("order is already cancelled")
+	ErrQuotaExceeded  = errors.New("order quota exceeded")
 )
 
-func (os *OrderStorage) SaveOrder(ctx context.Context, orderDTO *dto.OrderDTO) (*dto.OrderDTO, error) {
+func (os *OrderStorage) SaveOrder(ctx context.Context, order *domain.Order) (*domain.Order, error) {
+	return os.SaveOrderWithinQuota(ctx, order, models.OrderQuota{})
+}
+
+// SaveOrderWithinQuota saves the order and, in the same transaction, counts
+// it against the caller's quota for the day. When the caller has used up the
+// quota it fails with ErrQuotaExceeded and saves nothing.
+func (os *OrderStorage) SaveOrderWithinQuota(ctx context.Context, order *domain.Order, quota models.OrderQuota) (*domain.Order, error) {
 	const op = "data.SaveOrder"
 	fail := func(e error) error {
 		return fmt.Errorf("%s: %v", op, e)
 	}
+
+	orderDTO, err := dto.FromDomain(order)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	insertItemQuery := `INSERT INTO order_service.orders (user_id, item_id)
             VALUES ($1, $2)
-            RETURNING id`
+            RETURNING id, status, created_at`
 	args := []interface{}{
 		orderDTO.UserId,
 		orderDTO.ItemId,
@@ -38,7 +58,27 @@ func (os *OrderStorage) SaveOrder(ctx context.Context, orderDTO *dto.OrderDTO) (
 	}
 	defer tx.Rollback()
 
-	err = os.DB.QueryRowContext(ctx, insertItemQuery, args...).Scan(&orderDTO.ID)
+	if quota.Limit > 0 {
+		// The upsert takes the row lock of the caller's day, so concurrent
+		// orders of one caller are counted one after another.
+		quotaQuery := `
+			INSERT INTO order_service.order_quota (user_id, day, orders)
+			VALUES ($1, $2, 1)
+			ON CONFLICT (user_id, day) DO UPDATE
+			SET orders = order_quota.orders + 1
+			WHERE order_quota.orders < $3
+			RETURNING orders`
+		var orders int
+		err := tx.QueryRowContext(ctx, quotaQuery, quota.CallerId, quota.Day.Format(time.DateOnly), quota.Limit).Scan(&orders)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, ErrQuotaExceeded)
+		}
+		if err != nil {
+			return nil, fail(err)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, insertItemQuery, args...).Scan(&orderDTO.ID, &orderDTO.Status, &orderDTO.CreatedAt)
 	if err != nil || orderDTO.ID == 0 {
 		var pqErr *pq.Error
 		switch {
@@ -56,7 +96,7 @@ func (os *OrderStorage) SaveOrder(ctx context.Context, orderDTO *dto.OrderDTO) (
 	}
 
 	getOrderItemQuery := `
-				SELECT * FROM catalogue.item_info
+				SELECT id, name, price, description, quantity, image_url FROM catalogue.item_info
 				WHERE id = $1`
 	err = os.DB.QueryRowContext(ctx, getOrderItemQuery, orderDTO.ItemId).Scan(
 		&orderDTO.Item.ID,
@@ -67,22 +107,49 @@ func (os *OrderStorage) SaveOrder(ctx context.Context, orderDTO *dto.OrderDTO) (
 		&orderDTO.Item.ImageURL,
 	)
 
-	return orderDTO, nil
+	return orderDTO.Domain(), nil
 }
 
-func (os *OrderStorage) GetOrderById(ctx context.Context, id int) (*models.Order, error) {
-	const op = "data.GetOrderById"
-	fail := func(e error) error {
-		return fmt.Errorf("%s: %v", op, e)
-	}
-	var order models.Order
-	query := `SELECT * FROM order_service.orders
-			WHERE id = $1`
-	err := os.DB.QueryRowContext(ctx, query, id).Scan(
+// orderColumns selects an order joined with its item, in the order
+// scanOrder reads them.
+const orderColumns = `o.id, o.user_id, o.item_id, o.status, o.created_at,
+			       i.id, i.name, i.price, i.description, i.quantity, i.image_url`
+
+// scanOrder reads orderColumns, followed by extra, into an order row.
+func scanOrder(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*dto.OrderDTO, error) {
+	var order dto.OrderDTO
+	dest := append([]interface{}{
 		&order.ID,
 		&order.UserId,
 		&order.ItemId,
-	)
+		&order.Status,
+		&order.CreatedAt,
+		&order.Item.ID,
+		&order.Item.Name,
+		&order.Item.Price,
+		&order.Item.Description,
+		&order.Item.Quantity,
+		&order.Item.ImageURL,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (os *OrderStorage) GetOrderById(ctx context.Context, id int) (*domain.Order, error) {
+	const op = "data.GetOrderById"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+	query := `
+			SELECT ` + orderColumns + `
+			FROM order_service.orders o
+			INNER JOIN catalogue.item_info i
+			ON o.item_id = i.id
+			WHERE o.id = $1`
+	order, err := scanOrder(os.DB.QueryRowContext(ctx, query, id))
 
 	if err != nil {
 		switch {
@@ -92,84 +159,147 @@ func (os *OrderStorage) GetOrderById(ctx context.Context, id int) (*models.Order
 			return nil, fail(err)
 		}
 	}
-	return &order, nil
+	return order.Domain(), nil
 }
 
-func (os *OrderStorage) GetAllOrders(ctx context.Context) ([]*models.Order, error) {
+func (os *OrderStorage) GetAllOrders(ctx context.Context, filter models.OrderFilter) ([]*domain.Order, error) {
 	const op = "data.GetAllOrders"
 	fail := func(e error) error {
 		return fmt.Errorf("%s, %v", op, e)
 	}
-	query := `SELECT * FROM order_service.orders`
-	rows, err := os.DB.QueryContext(ctx, query)
+	query := `
+			SELECT ` + orderColumns + `
+			FROM order_service.orders o
+			INNER JOIN catalogue.item_info i
+			ON o.item_id = i.id
+			WHERE ($1 = 0 OR o.user_id = $1)
+			  AND ($2 = '' OR o.status = $2)
+			ORDER BY o.id
+			LIMIT NULLIF($3, 0) OFFSET $4`
+	args := []interface{}{
+		filter.UserId,
+		filter.Status,
+		filter.Limit,
+		filter.Offset,
+	}
+	rows, err := os.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fail(err)
 	}
+	defer rows.Close()
 
-	var orders []*models.Order
+	var orders []*domain.Order
 	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(
-			&order.ID,
-			&order.UserId,
-			&order.ItemId,
-		)
+		order, err := scanOrder(rows)
 		if err != nil {
 			return nil, fail(err)
 		}
 
-		orders = append(orders, &order)
+		orders = append(orders, order.Domain())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail(err)
 	}
 
 	return orders, nil
 }
 
-func (os *OrderStorage) GetOrdersByUserId(ctx context.Context, userId int) ([]*dto.OrderDTO, error) {
+func (os *OrderStorage) GetOrdersByUserId(ctx context.Context, userId int) ([]*domain.Order, error) {
 	const op = "data.GetOrdersByUserId"
 	fail := func(e error) error {
 		return fmt.Errorf("%s: %v", op, e)
 	}
 
 	query := `
-			SELECT o.*,
-			       i.*
+			SELECT ` + orderColumns + `
 			FROM order_service.orders o
 			INNER JOIN catalogue.item_info i
 			ON o.item_id = i.id
 			WHERE user_id = $1
-`
-	stmt, err := os.DB.PrepareContext(ctx, query)
+			ORDER BY o.id`
+	rows, err := os.DB.QueryContext(ctx, query, userId)
 	if err != nil {
 		return nil, fail(err)
 	}
+	defer rows.Close()
 
-	rows, err := stmt.QueryContext(ctx, userId)
-	if err != nil {
+	var orders []*domain.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fail(err)
+		}
+
+		orders = append(orders, order.Domain())
+	}
+	if err := rows.Err(); err != nil {
 		return nil, fail(err)
 	}
 
-	var orders []*dto.OrderDTO
+	return orders, nil
+}
+
+// SearchOrders matches the filter query against the name and description of
+// ordered items, best matches first. It also returns how many orders match
+// in total, ignoring Limit and Offset.
+func (os *OrderStorage) SearchOrders(ctx context.Context, filter models.SearchFilter) ([]*domain.Order, int, error) {
+	const op = "data.SearchOrders"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	matches := `
+			FROM order_service.orders o
+			INNER JOIN catalogue.item_info i
+			ON o.item_id = i.id,
+			websearch_to_tsquery('simple', $1) q
+			WHERE i.search_vector @@ q
+			  AND ($2 = 0 OR o.user_id = $2)
+			  AND ($3::timestamptz IS NULL OR o.created_at >= $3)
+			  AND ($4::timestamptz IS NULL OR o.created_at < $4)`
+	query := `
+			SELECT ` + orderColumns + `,
+			       count(*) OVER ()` + matches + `
+			ORDER BY ts_rank(i.search_vector, q) DESC, o.id DESC
+			LIMIT $5 OFFSET $6`
+	args := []interface{}{
+		filter.Query,
+		filter.UserId,
+		sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()},
+		sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()},
+		filter.Limit,
+		filter.Offset,
+	}
+	rows, err := os.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fail(err)
+	}
+	defer rows.Close()
+
+	var orders []*domain.Order
+	var total int
 	for rows.Next() {
-		var order dto.OrderDTO
-		err := rows.Scan(
-			&order.ID,
-			&order.UserId,
-			&order.ItemId,
-			&order.Item.ID,
-			&order.Item.Name,
-			&order.Item.Price,
-			&order.Item.Description,
-			&order.Item.Quantity,
-			&order.Item.ImageURL,
-		)
+		order, err := scanOrder(rows, &total)
 		if err != nil {
-			return nil, fail(err)
+			return nil, 0, fail(err)
 		}
 
-		orders = append(orders, &order)
+		orders = append(orders, order.Domain())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fail(err)
 	}
 
-	return orders, nil
+	// The window count rides on the returned rows, so an empty page past
+	// the first needs its own count.
+	if len(orders) == 0 && (filter.Offset > 0 || filter.Limit <= 0) {
+		err := os.DB.QueryRowContext(ctx, `SELECT count(*)`+matches, args[:4]...).Scan(&total)
+		if err != nil {
+			return nil, 0, fail(err)
+		}
+	}
+
+	return orders, total, nil
 }
 
 func (os *OrderStorage) DeleteOrderById(ctx context.Context, id int) error {
@@ -202,3 +332,39 @@ func (os *OrderStorage) DeleteOrderById(ctx context.Context, id int) error {
 
 	return nil
 }
+
+func (os *OrderStorage) CancelOrder(ctx context.Context, id int) error {
+	const op = "data.CancelOrder"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+	query := `
+			UPDATE order_service.orders
+			SET status = $2
+			WHERE id = $1 AND status <> $2`
+
+	exec, err := os.DB.ExecContext(ctx, query, id, models.StatusCancelled)
+	if err != nil {
+		return fail(err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return fail(err)
+	}
+
+	if affected == 0 {
+		// Nothing changed: either there is no such order or it is already
+		// cancelled. A failed lookup is reported as it is.
+		_, err := os.GetOrderById(ctx, id)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return fail(ErrRecordNotFound)
+		case err != nil:
+			return fail(err)
+		}
+		return fail(ErrOrderCancelled)
+	}
+
+	return nil
+}
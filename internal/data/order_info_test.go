@@ -0,0 +1,445 @@
+package data_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/lib/pq"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	ctx       = context.Background()
+	createdAt = time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+)
+
+func newMock(t *testing.T) (*data.OrderStorage, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+
+	return &data.OrderStorage{DB: db}, mock
+}
+
+var (
+	orderColumns  = []string{"id", "user_id", "item_id", "status", "created_at"}
+	joinedColumns = []string{"id", "user_id", "item_id", "status", "created_at", "id", "name", "price", "description", "quantity", "image_url"}
+)
+
+// addOrder adds an order of the bowl with the given id to a joinedColumns
+// result.
+func addOrder(rows *sqlmock.Rows, id, userId, itemId int32, status string) *sqlmock.Rows {
+	return rows.AddRow(id, userId, itemId, status, createdAt, itemId, "bowl", 500, "steel", 10, "https://example.com/bowl.png")
+}
+
+func bowl(id int32) domain.Item {
+	return domain.Item{ID: id, Name: "bowl", Price: 500, Description: "steel", Quantity: 10, ImageURL: "https://example.com/bowl.png"}
+}
+
+func TestSaveOrder(t *testing.T) {
+	storage, mock := newMock(t)
+	// With one connection, an insert made outside the transaction would
+	// wait for the transaction's connection until the deadline.
+	storage.DB.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO order_service.orders \(user_id, item_id\)`).
+		WithArgs(int32(7), int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "created_at"}).AddRow(42, models.StatusCreated, createdAt))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT id, name, price, description, quantity, image_url FROM catalogue.item_info`).
+		WithArgs(int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "quantity", "image_url"}).
+			AddRow(3, "bowl", 500, "steel", 10, "https://example.com/bowl.png"))
+
+	got, err := storage.SaveOrder(ctx, domain.NewOrder(7, domain.Item{ID: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &domain.Order{
+		ID: 42, UserId: 7, Status: models.StatusCreated, CreatedAt: createdAt,
+		Lines: []domain.Line{{Item: bowl(3), Quantity: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSaveOrder_Lines(t *testing.T) {
+	storage, _ := newMock(t)
+
+	orders := []*domain.Order{
+		domain.NewOrder(7),
+		domain.NewOrder(7, domain.Item{ID: 3}, domain.Item{ID: 4}),
+		{UserId: 7, Lines: []domain.Line{{Item: domain.Item{ID: 3}, Quantity: 2}}},
+	}
+	for _, o := range orders {
+		if _, err := storage.SaveOrder(ctx, o); !errors.Is(err, dto.ErrLines) {
+			t.Errorf("%+v: got %v, want ErrLines", o.Lines, err)
+		}
+	}
+}
+
+func TestSaveOrder_Error(t *testing.T) {
+	storage, mock := newMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO order_service.orders`).
+		WillReturnError(&pq.Error{Code: "23503", Message: "violates foreign key constraint"})
+	mock.ExpectRollback()
+
+	if _, err := storage.SaveOrder(ctx, domain.NewOrder(7, domain.Item{ID: 3})); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestGetOrderById(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		err     error
+		wantErr error
+	}{
+		{name: "found", rows: addOrder(sqlmock.NewRows(joinedColumns), 5, 7, 3, models.StatusCreated)},
+		{name: "not found", rows: sqlmock.NewRows(joinedColumns), wantErr: data.ErrRecordNotFound},
+		{name: "failure", err: sql.ErrConnDone, wantErr: sql.ErrConnDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMock(t)
+			q := mock.ExpectQuery(`INNER JOIN catalogue.item_info i\s+ON o.item_id = i.id\s+WHERE o.id = \$1`).WithArgs(5)
+			if tt.err != nil {
+				q.WillReturnError(tt.err)
+			} else {
+				q.WillReturnRows(tt.rows)
+			}
+
+			got, err := storage.GetOrderById(ctx, 5)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &domain.Order{
+				ID: 5, UserId: 7, Status: models.StatusCreated, CreatedAt: createdAt,
+				Lines: []domain.Line{{Item: bowl(3), Quantity: 1}},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetAllOrders(t *testing.T) {
+	storage, mock := newMock(t)
+
+	filter := models.OrderFilter{UserId: 7, Status: models.StatusCancelled, Limit: 2, Offset: 4}
+	rows := sqlmock.NewRows(joinedColumns)
+	addOrder(rows, 5, 7, 3, models.StatusCancelled)
+	addOrder(rows, 9, 7, 4, models.StatusCancelled)
+	mock.ExpectQuery(`ON o.item_id = i.id\s+WHERE \(\$1 = 0 OR o.user_id = \$1\)`).
+		WithArgs(filter.UserId, filter.Status, filter.Limit, filter.Offset).
+		WillReturnRows(rows)
+
+	got, err := storage.GetAllOrders(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != 5 || !reflect.DeepEqual(got[1].ItemIds(), []int32{4}) || !got[1].Cancelled() {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestSearchOrders(t *testing.T) {
+	storage, mock := newMock(t)
+
+	filter := models.SearchFilter{Query: "bowl", Limit: 1, Offset: 1}
+	rows := sqlmock.NewRows(append(joinedColumns, "count")).
+		AddRow(5, 7, 3, models.StatusCreated, createdAt, 3, "bowl", 500, "steel", 10, "https://example.com/bowl.png", 4)
+	mock.ExpectQuery(`count\(\*\) OVER \(\)`).
+		WithArgs(filter.Query, filter.UserId, sqlmock.AnyArg(), sqlmock.AnyArg(), filter.Limit, filter.Offset).
+		WillReturnRows(rows)
+
+	got, total, err := storage.SearchOrders(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 5 || total != 4 {
+		t.Errorf("got %v, total %d", got, total)
+	}
+}
+
+func TestSearchOrders_PastTheEnd(t *testing.T) {
+	storage, mock := newMock(t)
+
+	filter := models.SearchFilter{Query: "bowl", Limit: 10, Offset: 20}
+	mock.ExpectQuery(`count\(\*\) OVER \(\)`).
+		WithArgs(filter.Query, filter.UserId, sqlmock.AnyArg(), sqlmock.AnyArg(), filter.Limit, filter.Offset).
+		WillReturnRows(sqlmock.NewRows(append(joinedColumns, "count")))
+	mock.ExpectQuery(`SELECT count\(\*\)\s+FROM order_service.orders o`).
+		WithArgs(filter.Query, filter.UserId, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	got, total, err := storage.SearchOrders(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 || total != 4 {
+		t.Errorf("got %v, total %d", got, total)
+	}
+}
+
+func TestSaveOrderWithinQuota(t *testing.T) {
+	storage, mock := newMock(t)
+	quota := models.OrderQuota{CallerId: 9, Limit: 3, Day: createdAt}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO order_service.order_quota`).
+		WithArgs(int32(9), "2024-03-15", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"orders"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO order_service.orders`).
+		WithArgs(int32(7), int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "created_at"}).AddRow(42, models.StatusCreated, createdAt))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT id, name, price, description, quantity, image_url FROM catalogue.item_info`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "description", "quantity", "image_url"}).
+			AddRow(3, "bowl", 500, "steel", 10, "https://example.com/bowl.png"))
+
+	got, err := storage.SaveOrderWithinQuota(ctx, domain.NewOrder(7, domain.Item{ID: 3}), quota)
+	if err != nil || got.ID != 42 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestSaveOrderWithinQuota_Exceeded(t *testing.T) {
+	storage, mock := newMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO order_service.order_quota`).
+		WillReturnRows(sqlmock.NewRows([]string{"orders"}))
+	mock.ExpectRollback()
+
+	_, err := storage.SaveOrderWithinQuota(ctx, domain.NewOrder(7, domain.Item{ID: 3}), models.OrderQuota{CallerId: 9, Limit: 3, Day: createdAt})
+	if !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Errorf("got %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestGetOrdersByUserId(t *testing.T) {
+	storage, mock := newMock(t)
+
+	mock.ExpectQuery(`WHERE user_id = \$1\s+ORDER BY o.id`).
+		WithArgs(7).
+		WillReturnRows(addOrder(sqlmock.NewRows(joinedColumns), 5, 7, 3, models.StatusCreated))
+
+	got, err := storage.GetOrdersByUserId(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 5 || got[0].Lines[0].Item != bowl(3) || got[0].Total() != 500 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestListOrders_RowError checks that an error while reading rows is not
+// mistaken for the end of the result.
+func TestListOrders_RowError(t *testing.T) {
+	broken := errors.New("connection reset")
+	tests := []struct {
+		name string
+		list func(*data.OrderStorage) ([]*domain.Order, error)
+	}{
+		{"all", func(s *data.OrderStorage) ([]*domain.Order, error) {
+			return s.GetAllOrders(ctx, models.OrderFilter{})
+		}},
+		{"by user", func(s *data.OrderStorage) ([]*domain.Order, error) {
+			return s.GetOrdersByUserId(ctx, 7)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMock(t)
+
+			rows := sqlmock.NewRows(joinedColumns)
+			addOrder(rows, 5, 7, 3, models.StatusCreated)
+			addOrder(rows, 9, 7, 4, models.StatusCreated)
+			rows.RowError(1, broken)
+			mock.ExpectQuery(`INNER JOIN catalogue.item_info i`).WillReturnRows(rows)
+
+			if got, err := tt.list(storage); err == nil {
+				t.Errorf("got %v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		exists   bool
+		err      error
+		lookErr  error
+		wantErr  error
+	}{
+		{name: "cancelled", affected: 1},
+		{name: "already cancelled", exists: true, wantErr: data.ErrOrderCancelled},
+		{name: "missing", wantErr: data.ErrRecordNotFound},
+		{name: "failure", err: sql.ErrConnDone, wantErr: sql.ErrConnDone},
+		{name: "lookup failure", lookErr: sql.ErrConnDone, wantErr: sql.ErrConnDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMock(t)
+
+			exec := mock.ExpectExec(`UPDATE order_service.orders\s+SET status = \$2`).WithArgs(5, models.StatusCancelled)
+			if tt.err != nil {
+				exec.WillReturnError(tt.err)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			}
+			if tt.err == nil && tt.affected == 0 {
+				rows := sqlmock.NewRows(joinedColumns)
+				if tt.exists {
+					addOrder(rows, 5, 7, 3, models.StatusCancelled)
+				}
+				q := mock.ExpectQuery(`WHERE o.id = \$1`).WithArgs(5)
+				if tt.lookErr != nil {
+					q.WillReturnError(tt.lookErr)
+				} else {
+					q.WillReturnRows(rows)
+				}
+			}
+
+			err := storage.CancelOrder(ctx, 5)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.lookErr != nil && errors.Is(err, data.ErrRecordNotFound) {
+				t.Errorf("lookup failure reported as %v", err)
+			}
+		})
+	}
+}
+
+func TestExistingUsers(t *testing.T) {
+	storage, mock := newMock(t)
+
+	mock.ExpectQuery(`SELECT id FROM sso.users WHERE id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int32{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	got, err := storage.ExistingUsers(ctx, []int32{1, 2})
+	if err != nil || got[1] || !got[2] {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	// No ids, no query.
+	if got, err := storage.ExistingItems(ctx, nil); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestImportOrders(t *testing.T) {
+	orders := func() []*models.Order {
+		return []*models.Order{
+			{UserId: 1, ItemId: 2, Status: models.StatusCreated, CreatedAt: createdAt},
+			{UserId: 3, ItemId: 4, Status: models.StatusCancelled, CreatedAt: createdAt},
+		}
+	}
+	insert := `INSERT INTO order_service.orders \(user_id, item_id, status, created_at\) VALUES \(\$1, \$2, \$3, \$4\), \(\$5, \$6, \$7, \$8\) RETURNING id`
+
+	t.Run("stored", func(t *testing.T) {
+		storage, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insert).
+			WithArgs(int32(1), int32(2), models.StatusCreated, createdAt, int32(3), int32(4), models.StatusCancelled, createdAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+		mock.ExpectCommit()
+
+		o := orders()
+		if err := storage.ImportOrders(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+		if o[0].ID != 10 || o[1].ID != 11 {
+			t.Errorf("ids %d, %d", o[0].ID, o[1].ID)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		err      error
+		rejected bool
+	}{
+		{"foreign key", &pq.Error{Code: "23503"}, true},
+		{"bad data", &pq.Error{Code: "22007"}, true},
+		{"server", &pq.Error{Code: "57P01"}, false},
+		{"connection", sql.ErrConnDone, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMock(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(insert).WillReturnError(tt.err)
+			mock.ExpectRollback()
+
+			err := storage.ImportOrders(ctx, orders())
+			if err == nil || errors.Is(err, data.ErrRejected) != tt.rejected {
+				t.Errorf("got %v, rejected %v", err, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestExportOrders(t *testing.T) {
+	storage, mock := newMock(t)
+
+	columns := joinedColumns
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE export_orders NO SCROLL CURSOR FOR`).
+		WithArgs(sql.NullTime{}, sql.NullTime{Time: createdAt, Valid: true}, "", int32(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH FORWARD 1000 FROM export_orders`).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(5, 7, 3, models.StatusCreated, createdAt, 3, "bowl", 500, "", 10, "").
+			AddRow(6, 7, 3, models.StatusCreated, createdAt, 3, "bowl", 500, "", 10, ""))
+	mock.ExpectRollback()
+
+	var ids []int32
+	err := storage.ExportOrders(ctx, models.ExportFilter{To: createdAt, UserId: 7}, func(o *dto.OrderDTO) error {
+		ids = append(ids, o.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 5 || ids[1] != 6 {
+		t.Errorf("got ids %v", ids)
+	}
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"time"
+)
+
+// Sources the report queries aggregate over, both yielding
+// (at, item_id, user_id, orders, revenue) rows for orders that count.
+const (
+	liveSales = `
+			SELECT o.created_at AS at, o.item_id, o.user_id, 1 AS orders, i.price AS revenue
+			FROM order_service.orders o
+			INNER JOIN catalogue.item_info i
+			ON o.item_id = i.id
+			WHERE o.status <> 'cancelled'`
+	viewSales = `
+			SELECT day::timestamp AT TIME ZONE 'UTC' AS at, item_id, user_id, orders, revenue
+			FROM order_service.daily_sales`
+)
+
+// salesSource picks the materialized view when it can answer the filter
+// exactly: views are enabled, periods are cut in UTC and the range starts
+// and ends on UTC midnight. Otherwise orders are read directly.
+func (os *OrderStorage) salesSource(filter models.ReportFilter) string {
+	if !os.ReportViews || filter.Timezone != "UTC" || !utcMidnight(filter.From) || !utcMidnight(filter.To) {
+		return liveSales
+	}
+	return viewSales
+}
+
+func utcMidnight(t time.Time) bool {
+	return t.IsZero() || t.UTC().Truncate(24*time.Hour).Equal(t)
+}
+
+func timeRange(filter models.ReportFilter) (sql.NullTime, sql.NullTime) {
+	return sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()},
+		sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+}
+
+// SalesByPeriod totals orders and revenue per day, week or month.
+func (os *OrderStorage) SalesByPeriod(ctx context.Context, filter models.ReportFilter) ([]models.PeriodSales, error) {
+	const op = "data.SalesByPeriod"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	query := `
+			SELECT date_trunc($3, s.at AT TIME ZONE $4) AS period, sum(s.orders), sum(s.revenue)
+			FROM (` + os.salesSource(filter) + `) s
+			WHERE ($1::timestamptz IS NULL OR s.at >= $1)
+			  AND ($2::timestamptz IS NULL OR s.at < $2)
+			GROUP BY period
+			ORDER BY period`
+	from, to := timeRange(filter)
+
+	rows, err := os.DB.QueryContext(ctx, query, from, to, filter.Granularity, filter.Timezone)
+	if err != nil {
+		return nil, fail(err)
+	}
+	defer rows.Close()
+
+	var sales []models.PeriodSales
+	for rows.Next() {
+		var s models.PeriodSales
+		if err := rows.Scan(&s.Period, &s.Orders, &s.Revenue); err != nil {
+			return nil, fail(err)
+		}
+		sales = append(sales, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail(err)
+	}
+
+	return sales, nil
+}
+
+// TopItems returns the filter.Limit best selling items by quantity or by
+// revenue.
+func (os *OrderStorage) TopItems(ctx context.Context, filter models.ReportFilter) ([]models.ItemSales, error) {
+	const op = "data.TopItems"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	query := `
+			SELECT s.item_id, i.name, sum(s.orders) AS orders, sum(s.revenue) AS revenue
+			FROM (` + os.salesSource(filter) + `) s
+			INNER JOIN catalogue.item_info i
+			ON s.item_id = i.id
+			WHERE ($1::timestamptz IS NULL OR s.at >= $1)
+			  AND ($2::timestamptz IS NULL OR s.at < $2)
+			GROUP BY s.item_id, i.name
+			ORDER BY CASE WHEN $3 = 'revenue' THEN sum(s.revenue) ELSE sum(s.orders) END DESC, s.item_id
+			LIMIT $4`
+	from, to := timeRange(filter)
+
+	rows, err := os.DB.QueryContext(ctx, query, from, to, filter.By, filter.Limit)
+	if err != nil {
+		return nil, fail(err)
+	}
+	defer rows.Close()
+
+	var items []models.ItemSales
+	for rows.Next() {
+		var s models.ItemSales
+		if err := rows.Scan(&s.ItemId, &s.Name, &s.Orders, &s.Revenue); err != nil {
+			return nil, fail(err)
+		}
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail(err)
+	}
+
+	return items, nil
+}
+
+// SalesByUser returns the filter.Limit users with the most orders.
+func (os *OrderStorage) SalesByUser(ctx context.Context, filter models.ReportFilter) ([]models.UserSales, error) {
+	const op = "data.SalesByUser"
+	fail := func(e error) error {
+		return fmt.Errorf("%s: %w", op, e)
+	}
+
+	query := `
+			SELECT s.user_id, sum(s.orders) AS orders, sum(s.revenue) AS revenue
+			FROM (` + os.salesSource(filter) + `) s
+			WHERE ($1::timestamptz IS NULL OR s.at >= $1)
+			  AND ($2::timestamptz IS NULL OR s.at < $2)
+			GROUP BY s.user_id
+			ORDER BY orders DESC, s.user_id
+			LIMIT $3`
+	from, to := timeRange(filter)
+
+	rows, err := os.DB.QueryContext(ctx, query, from, to, filter.Limit)
+	if err != nil {
+		return nil, fail(err)
+	}
+	defer rows.Close()
+
+	var users []models.UserSales
+	for rows.Next() {
+		var s models.UserSales
+		if err := rows.Scan(&s.UserId, &s.Orders, &s.Revenue); err != nil {
+			return nil, fail(err)
+		}
+		users = append(users, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail(err)
+	}
+
+	return users, nil
+}
+
+// RefreshReportViews recomputes the materialized views behind the reports
+// without blocking readers.
+func (os *OrderStorage) RefreshReportViews(ctx context.Context) error {
+	const op = "data.RefreshReportViews"
+
+	_, err := os.DB.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY order_service.daily_sales`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
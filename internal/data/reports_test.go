@@ -0,0 +1,78 @@
+package data_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"testing"
+	"time"
+)
+
+func TestSalesByPeriod_Source(t *testing.T) {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		views  bool
+		filter models.ReportFilter
+		source string
+	}{
+		{"views off", false, models.ReportFilter{Timezone: "UTC"}, "FROM order_service.orders o"},
+		{"whole days", true, models.ReportFilter{Timezone: "UTC", From: midnight, To: midnight.AddDate(0, 1, 0)}, "FROM order_service.daily_sales"},
+		{"open range", true, models.ReportFilter{Timezone: "UTC"}, "FROM order_service.daily_sales"},
+		{"time zone", true, models.ReportFilter{Timezone: "Asia/Almaty"}, "FROM order_service.orders o"},
+		{"partial day", true, models.ReportFilter{Timezone: "UTC", From: midnight.Add(time.Hour)}, "FROM order_service.orders o"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMock(t)
+			storage.ReportViews = tt.views
+			tt.filter.Granularity = models.GranularityDay
+
+			mock.ExpectQuery(tt.source).
+				WillReturnRows(sqlmock.NewRows([]string{"period", "orders", "revenue"}).AddRow(midnight, 3, 1200))
+
+			got, err := storage.SalesByPeriod(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !got[0].Period.Equal(midnight) || got[0].Orders != 3 || got[0].Revenue != 1200 {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestTopItems(t *testing.T) {
+	storage, mock := newMock(t)
+
+	filter := models.ReportFilter{Timezone: "UTC", By: models.ByRevenue, Limit: 5}
+	mock.ExpectQuery(`ORDER BY CASE WHEN \$3 = 'revenue'`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.ByRevenue, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "name", "orders", "revenue"}).AddRow(3, "bed", 1, 1000))
+
+	got, err := storage.TopItems(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.ItemSales{ItemId: 3, Name: "bed", Sales: models.Sales{Orders: 1, Revenue: 1000}}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSalesByUser(t *testing.T) {
+	storage, mock := newMock(t)
+
+	mock.ExpectQuery(`GROUP BY s.user_id`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 10).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "orders", "revenue"}).AddRow(7, 2, 200).AddRow(8, 1, 1000))
+
+	got, err := storage.SalesByUser(ctx, models.ReportFilter{Timezone: "UTC", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].UserId != 7 || got[1].Revenue != 1000 {
+		t.Errorf("got %+v", got)
+	}
+}
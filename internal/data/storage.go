@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -15,3 +16,18 @@ func New(dsn string) (*OrderStorage, error) {
 
 	return &OrderStorage{DB: db}, nil
 }
+
+// Ping verifies that the database is reachable.
+func (os *OrderStorage) Ping(ctx context.Context) error {
+	const op = "data.Ping"
+
+	if err := os.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (os *OrderStorage) Close() error {
+	return os.DB.Close()
+}
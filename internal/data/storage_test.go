@@ -0,0 +1,99 @@
+package data_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/datatest"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/seed"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testDatabaseEnv names a disposable database the tests may wipe. The tests
+// are skipped without it.
+const testDatabaseEnv = "ORDER_TEST_DATABASE_URL"
+
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	storage, err := data.New(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+
+	ctx := context.Background()
+	seeder := seed.New(storage.DB)
+	if err := seeder.EnsureSchema(ctx); err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, storage)
+
+	datatest.Run(t, func(t *testing.T) datatest.Fixture {
+		if err := seeder.Reset(ctx); err != nil {
+			t.Fatal(err)
+		}
+		users := 0
+
+		return datatest.Fixture{
+			Repo: storage,
+			AddUser: func(t *testing.T) int32 {
+				users++
+				var id int32
+				err := storage.DB.QueryRowContext(ctx, `INSERT INTO sso.users (email) VALUES ($1) RETURNING id`,
+					fmt.Sprintf("user%d@example.com", users)).Scan(&id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return id
+			},
+			AddItem: func(t *testing.T, item dto.ItemDTO) int32 {
+				var id int32
+				err := storage.DB.QueryRowContext(ctx, `
+					INSERT INTO catalogue.item_info (name, price, description, quantity, image_url)
+					VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+					item.Name, item.Price, item.Description, item.Quantity, item.ImageURL).Scan(&id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return id
+			},
+		}
+	})
+}
+
+// migrate applies the up migrations unless the orders table exists already.
+func migrate(t *testing.T, storage *data.OrderStorage) {
+	t.Helper()
+
+	var exists bool
+	err := storage.DB.QueryRow(`SELECT to_regclass('order_service.orders') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		return
+	}
+
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := storage.DB.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package domain holds the order aggregate the services work with. Storage
+// rows stay in internal/data and API messages in the gRPC layer; both are
+// converted to and from these types at the edges.
+package domain
+
+import "time"
+
+// Order statuses. An order starts out created and can only be cancelled.
+const (
+	StatusCreated   = "created"
+	StatusCancelled = "cancelled"
+)
+
+// Item is a catalogue item as it was when the order was read.
+type Item struct {
+	ID          int32
+	Name        string
+	Description string
+	ImageURL    string
+	Price       int32
+	// Quantity is the stock the catalogue reports, not the amount ordered.
+	Quantity int32
+}
+
+// Line is an ordered item and how many of it were ordered.
+type Line struct {
+	Item     Item
+	Quantity int32
+}
+
+// Total is the price of the line.
+func (l Line) Total() int64 {
+	return int64(l.Item.Price) * int64(l.Quantity)
+}
+
+// Order is an order placed by a user. Storage keeps a single line of one
+// item per order and prices it at the current item price.
+type Order struct {
+	ID        int32
+	UserId    int32
+	Status    string
+	Lines     []Line
+	CreatedAt time.Time
+}
+
+// NewOrder starts an order of one of each of the given items.
+func NewOrder(userId int32, items ...Item) *Order {
+	lines := make([]Line, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, Line{Item: item, Quantity: 1})
+	}
+
+	return &Order{UserId: userId, Status: StatusCreated, Lines: lines}
+}
+
+// Total is the price of all lines.
+func (o *Order) Total() int64 {
+	var total int64
+	for _, l := range o.Lines {
+		total += l.Total()
+	}
+	return total
+}
+
+// ItemIds lists the ordered items in line order.
+func (o *Order) ItemIds() []int32 {
+	ids := make([]int32, 0, len(o.Lines))
+	for _, l := range o.Lines {
+		ids = append(ids, l.Item.ID)
+	}
+	return ids
+}
+
+// Cancelled reports whether the order was cancelled.
+func (o *Order) Cancelled() bool {
+	return o.Status == StatusCancelled
+}
@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"reflect"
+	"testing"
+)
+
+func TestNewOrder(t *testing.T) {
+	order := domain.NewOrder(7, domain.Item{ID: 3, Price: 450}, domain.Item{ID: 4, Price: 1200})
+
+	if order.UserId != 7 || order.Status != domain.StatusCreated || order.Cancelled() {
+		t.Errorf("got %+v", order)
+	}
+	if got := order.ItemIds(); !reflect.DeepEqual(got, []int32{3, 4}) {
+		t.Errorf("got items %v", got)
+	}
+	for _, l := range order.Lines {
+		if l.Quantity != 1 {
+			t.Errorf("line %+v, want quantity 1", l)
+		}
+	}
+	if got := order.Total(); got != 1650 {
+		t.Errorf("got total %d, want 1650", got)
+	}
+}
+
+func TestTotal(t *testing.T) {
+	order := &domain.Order{Lines: []domain.Line{
+		{Item: domain.Item{Price: 2_000_000_000}, Quantity: 3},
+		{Item: domain.Item{Price: 5}, Quantity: 0},
+	}}
+
+	if got := order.Total(); got != 6_000_000_000 {
+		t.Errorf("got %d, want 6000000000", got)
+	}
+	if got := (&domain.Order{}).Total(); got != 0 {
+		t.Errorf("no lines: got %d", got)
+	}
+}
@@ -0,0 +1,452 @@
+package e2e_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/e2e"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func wantCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got %v (%v), want %v", got, err, want)
+	}
+}
+
+func mustStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// createOrder places an order as caller and fails the test when it is
+// rejected.
+func createOrder(t *testing.T, h *e2e.Harness, caller e2e.Caller, itemId int32) *orderv20.Order {
+	t.Helper()
+	resp, err := h.Orders.CreateOrder(caller.Context(context.Background()), &orderv20.CreateOrderRequest{
+		Order: &orderv20.Order{UserId: caller.Id, ItemId: itemId},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return resp.GetOrder()
+}
+
+func orderIds(orders []*orderv20.Order) []int32 {
+	ids := make([]int32, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.GetId())
+	}
+	return ids
+}
+
+// withCatalogueCheck turns on the catalogue lookup made before saving an
+// order, which is off by default.
+var withCatalogueCheck = e2e.WithConfig(func(cfg *config.Config) {
+	cfg.Features = map[string]bool{settings.FeatureCatalogueCheck: true}
+})
+
+func TestCreateOrder(t *testing.T) {
+	h := e2e.New(t, withCatalogueCheck)
+	customer := h.Customer()
+	item := h.AddItem("Salmon Cat Food", "Grain free", 700)
+
+	events, cancel := h.Events.Subscribe(1)
+	defer cancel()
+
+	order := createOrder(t, h, customer, item)
+	if order.GetId() == 0 || order.GetUserId() != customer.Id || order.GetItemId() != item {
+		t.Errorf("got order %v", order)
+	}
+	if h.Catalogue.Calls("GetItem") != 1 {
+		t.Errorf("catalogue asked %d times, want once", h.Catalogue.Calls("GetItem"))
+	}
+
+	var event struct {
+		UserInfo  struct{ Id int32 } `json:"user_info"`
+		OrderInfo struct {
+			Id     int32 `json:"id"`
+			ItemId int32 `json:"item_id"`
+		} `json:"order_info"`
+	}
+	select {
+	case body := <-events:
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event published")
+	}
+	if event.UserInfo.Id != customer.Id || event.OrderInfo.Id != order.GetId() || event.OrderInfo.ItemId != item {
+		t.Errorf("got event %+v", event)
+	}
+
+	stored, err := h.Storage.GetOrderById(context.Background(), int(order.GetId()))
+	if err != nil || stored.Status != models.StatusCreated {
+		t.Errorf("stored %+v, %v", stored, err)
+	}
+}
+
+func TestCreateOrder_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []e2e.Option
+		setup func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order)
+		want  codes.Code
+	}{
+		{
+			name: "invalid",
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id}
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "item not in catalogue",
+			opts: []e2e.Option{withCatalogueCheck},
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				h.Catalogue.RemoveItem(item)
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id, ItemId: item}
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "catalogue down",
+			opts: []e2e.Option{withCatalogueCheck},
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				h.Catalogue.FailWith(status.Error(codes.Unavailable, "down"))
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id, ItemId: item}
+			},
+			want: codes.Internal,
+		},
+		{
+			name: "item unknown to storage",
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id, ItemId: item + 100}
+			},
+			want: codes.Internal,
+		},
+		{
+			name: "quota",
+			opts: []e2e.Option{e2e.WithConfig(func(cfg *config.Config) {
+				cfg.RateLimits.DailyOrderQuota = 1
+			})},
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				createOrder(t, h, caller, item)
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id, ItemId: item}
+			},
+			want: codes.ResourceExhausted,
+		},
+		{
+			// The event names the user behind the token, so there must be one.
+			name: "no token",
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				return context.Background(), &orderv20.Order{UserId: caller.Id, ItemId: item}
+			},
+			want: codes.Internal,
+		},
+		{
+			name: "event bus down",
+			setup: func(h *e2e.Harness, caller e2e.Caller, item int32) (context.Context, *orderv20.Order) {
+				h.Events.FailWith(errors.New("down"))
+				return caller.Context(context.Background()), &orderv20.Order{UserId: caller.Id, ItemId: item}
+			},
+			want: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := e2e.New(t, tt.opts...)
+			ctx, order := tt.setup(h, h.Customer(), h.AddItem("bowl", "", 100))
+
+			_, err := h.Orders.CreateOrder(ctx, &orderv20.CreateOrderRequest{Order: order})
+			wantCode(t, err, tt.want)
+		})
+	}
+}
+
+func TestCreateOrder_NotificationsOff(t *testing.T) {
+	h := e2e.New(t, e2e.WithConfig(func(cfg *config.Config) {
+		cfg.Features = map[string]bool{settings.FeatureNotifications: false}
+	}))
+	customer := h.Customer()
+
+	_, err := h.Orders.CreateOrder(context.Background(), &orderv20.CreateOrderRequest{
+		Order: &orderv20.Order{UserId: customer.Id, ItemId: h.AddItem("bowl", "", 100)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(h.Events.Events()); n != 0 {
+		t.Errorf("published %d events", n)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	h := e2e.New(t)
+	customer := h.Customer()
+	order := createOrder(t, h, customer, h.AddItem("bowl", "", 100))
+
+	resp, err := h.Orders.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: strconv.Itoa(int(order.GetId()))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetOrder().GetId() != order.GetId() || resp.GetOrder().GetUserId() != customer.Id {
+		t.Errorf("got %v, want %v", resp.GetOrder(), order)
+	}
+
+	_, err = h.Orders.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: strconv.Itoa(int(order.GetId()) + 1)})
+	wantCode(t, err, codes.NotFound)
+
+	_, err = h.Orders.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: "first"})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestListOrders(t *testing.T) {
+	h := e2e.New(t)
+	admin, alice, bob := h.Admin(), h.Customer(), h.Customer()
+	item := h.AddItem("bowl", "", 100)
+	a1 := createOrder(t, h, alice, item)
+	b1 := createOrder(t, h, bob, item)
+	a2 := createOrder(t, h, alice, item)
+
+	list := func(pairs ...string) []int32 {
+		t.Helper()
+		ctx := metadata.AppendToOutgoingContext(admin.Context(context.Background()), pairs...)
+		resp, err := h.Orders.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return orderIds(resp.GetOrders())
+	}
+
+	tests := []struct {
+		name  string
+		pairs []string
+		want  []int32
+	}{
+		{"all", nil, []int32{a1.GetId(), b1.GetId(), a2.GetId()}},
+		{"user", []string{orderGrpc.MetadataListUserId, strconv.Itoa(int(alice.Id))}, []int32{a1.GetId(), a2.GetId()}},
+		{"page", []string{orderGrpc.MetadataListLimit, "1", orderGrpc.MetadataListOffset, "1"}, []int32{b1.GetId()}},
+		{"status", []string{orderGrpc.MetadataListStatus, models.StatusCancelled}, []int32{}},
+	}
+	for _, tt := range tests {
+		got := list(tt.pairs...)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+
+	ctx := metadata.AppendToOutgoingContext(admin.Context(context.Background()), orderGrpc.MetadataListLimit, "-1")
+	_, err := h.Orders.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestGetOrderByUserId(t *testing.T) {
+	h := e2e.New(t)
+	admin, customer := h.Admin(), h.Customer()
+	item := h.AddItem("bowl", "", 100)
+	order := createOrder(t, h, admin, item)
+	createOrder(t, h, customer, item)
+
+	resp, err := h.Orders.GetOrderByUserId(admin.Context(context.Background()), &orderv20.GetOrdersByUserId{UserId: admin.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderIds(resp.GetOrders()); len(got) != 1 || got[0] != order.GetId() {
+		t.Errorf("got orders %v, want [%d]", got, order.GetId())
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	h := e2e.New(t)
+	admin := h.Admin()
+	order := createOrder(t, h, h.Customer(), h.AddItem("bowl", "", 100))
+	ctx := admin.Context(context.Background())
+
+	resp, err := h.Extensions.CancelOrder(ctx, &orderv20.DeleteOrderRequest{Id: order.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetIsDeleted() {
+		t.Error("IsDeleted is false")
+	}
+
+	_, err = h.Extensions.CancelOrder(ctx, &orderv20.DeleteOrderRequest{Id: order.GetId()})
+	wantCode(t, err, codes.FailedPrecondition)
+
+	_, err = h.Extensions.CancelOrder(ctx, &orderv20.DeleteOrderRequest{Id: order.GetId() + 1})
+	wantCode(t, err, codes.NotFound)
+
+	_, err = h.Extensions.CancelOrder(ctx, &orderv20.DeleteOrderRequest{})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestSetLogLevel(t *testing.T) {
+	h := e2e.New(t)
+	ctx := h.Admin().Context(context.Background())
+
+	resp, err := h.Extensions.SetLogLevel(ctx, wrapperspb.String("warn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetValue() != "warn" || h.Settings.LogLevel() != slog.LevelWarn {
+		t.Errorf("got %q, level %v", resp.GetValue(), h.Settings.LogLevel())
+	}
+
+	resp, err = h.Extensions.SetLogLevel(ctx, wrapperspb.String(""))
+	if err != nil || resp.GetValue() != "warn" {
+		t.Errorf("reading the level: got %q, %v", resp.GetValue(), err)
+	}
+
+	_, err = h.Extensions.SetLogLevel(ctx, wrapperspb.String("verbose"))
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestSearchOrders(t *testing.T) {
+	h := e2e.New(t)
+	admin, customer := h.Admin(), h.Customer()
+	food := createOrder(t, h, customer, h.AddItem("Salmon Cat Food", "Grain free", 700))
+	createOrder(t, h, customer, h.AddItem("Dog Leash", "Red nylon", 900))
+
+	var header metadata.MD
+	resp, err := h.Extensions.SearchOrders(admin.Context(context.Background()),
+		mustStruct(t, map[string]interface{}{"query": "salmon"}), grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderIds(resp.GetOrders()); len(got) != 1 || got[0] != food.GetId() {
+		t.Errorf("got orders %v, want [%d]", got, food.GetId())
+	}
+	if total := header.Get(orderGrpc.MetadataTotalCount); len(total) != 1 || total[0] != "1" {
+		t.Errorf("got total %v, want 1", total)
+	}
+
+	_, err = h.Extensions.SearchOrders(admin.Context(context.Background()), mustStruct(t, map[string]interface{}{}))
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestReports(t *testing.T) {
+	h := e2e.New(t)
+	admin, customer := h.Admin(), h.Customer()
+	cheap, dear := h.AddItem("bowl", "", 100), h.AddItem("bed", "", 1000)
+	createOrder(t, h, customer, cheap)
+	createOrder(t, h, customer, cheap)
+	createOrder(t, h, admin, dear)
+	ctx := admin.Context(context.Background())
+
+	sales, err := h.Extensions.Report(ctx, orderGrpc.SalesReportMethod, mustStruct(t, map[string]interface{}{"granularity": "month"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := sales.AsMap()["total"].(map[string]interface{})
+	if total["orders"] != 3.0 || total["revenue"] != 1200.0 {
+		t.Errorf("sales total %v", total)
+	}
+
+	top, err := h.Extensions.Report(ctx, orderGrpc.TopItemsReportMethod, mustStruct(t, map[string]interface{}{"by": "revenue", "limit": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := top.AsMap()["items"].([]interface{})
+	if len(items) != 1 || items[0].(map[string]interface{})["name"] != "bed" {
+		t.Errorf("top items %v", items)
+	}
+
+	users, err := h.Extensions.Report(ctx, orderGrpc.UserOrdersReportMethod, mustStruct(t, map[string]interface{}{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := users.AsMap()["users"].([]interface{})
+	if len(rows) != 2 || rows[0].(map[string]interface{})["user_id"] != float64(customer.Id) {
+		t.Errorf("user orders %v", rows)
+	}
+
+	_, err = h.Extensions.Report(ctx, orderGrpc.SalesReportMethod, mustStruct(t, map[string]interface{}{"timezone": "Mars/Olympus"}))
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func importOrders(t *testing.T, h *e2e.Harness, ctx context.Context, rows ...map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	stream, err := h.Extensions.ImportOrders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := stream.Send(mustStruct(t, row)); err != nil {
+			break
+		}
+	}
+	report, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+	return report.AsMap(), nil
+}
+
+func TestImportOrders(t *testing.T) {
+	h := e2e.New(t)
+	admin, customer := h.Admin(), h.Customer()
+	item := h.AddItem("bowl", "", 100)
+	rows := []map[string]interface{}{
+		{"user_id": float64(customer.Id), "item_id": float64(item), "created_at": "2024-03-15T10:00:00Z"},
+		{"user_id": float64(customer.Id), "item_id": float64(item + 100)},
+		{"user_id": float64(customer.Id), "item_id": float64(item), "status": "shipped"},
+	}
+
+	dryCtx := metadata.AppendToOutgoingContext(admin.Context(context.Background()), orderGrpc.MetadataDryRun, "true")
+	report, err := importOrders(t, h, dryCtx, rows...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report["dry_run"] != true || report["imported"] != 1.0 || report["failed"] != 2.0 {
+		t.Errorf("dry run report %v", report)
+	}
+	if orders, _ := h.Storage.GetOrdersByUserId(context.Background(), int(customer.Id)); len(orders) != 0 {
+		t.Fatalf("dry run stored %d orders", len(orders))
+	}
+
+	report, err = importOrders(t, h, admin.Context(context.Background()), rows...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report["rows"] != 3.0 || report["imported"] != 1.0 || report["failed"] != 2.0 {
+		t.Errorf("report %v", report)
+	}
+	errs := report["errors"].([]interface{})
+	if len(errs) != 2 || errs[0].(map[string]interface{})["line"] != 2.0 || errs[1].(map[string]interface{})["field"] != "status" {
+		t.Errorf("row errors %v", errs)
+	}
+
+	orders, err := h.Storage.GetOrdersByUserId(context.Background(), int(customer.Id))
+	if err != nil || len(orders) != 1 || !orders[0].CreatedAt.Equal(time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("stored %v, %v", orders, err)
+	}
+}
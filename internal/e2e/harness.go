@@ -0,0 +1,266 @@
+// Package e2e boots the order service the way the app wires it, with the
+// real gRPC server, interceptors and services, on top of in-memory storage,
+// fake SSO and catalogue services and an in-process event bus. Everything
+// runs over in-memory connections, so end-to-end tests need no network.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	grpcapp "github.com/bxiit/order-service-pet-store/internal/app/grpc"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/memory"
+	"github.com/bxiit/order-service-pet-store/internal/events"
+	"github.com/bxiit/order-service-pet-store/internal/fake"
+	"github.com/bxiit/order-service-pet-store/internal/fake/catalogue"
+	"github.com/bxiit/order-service-pet-store/internal/fake/sso"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	"github.com/bxiit/order-service-pet-store/internal/services/report"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Repository is what the services need from storage.
+type Repository interface {
+	order.OrderRepo
+	report.ReportRepo
+	importer.ImportRepo
+}
+
+type options struct {
+	configure []func(*config.Config)
+	identity  identity.Options
+	wrap      func(Repository) Repository
+}
+
+type Option func(*options)
+
+// WithConfig adjusts the config before the service is built.
+func WithConfig(fn func(cfg *config.Config)) Option {
+	return func(o *options) {
+		o.configure = append(o.configure, fn)
+	}
+}
+
+// WithIdentity sets how the service calls SSO. By default answers are not
+// cached, so role changes apply at once, and failures are neither retried
+// nor counted by the circuit breaker.
+func WithIdentity(opts identity.Options) Option {
+	return func(o *options) {
+		o.identity = opts
+	}
+}
+
+// WithRepository puts wrap around the memory storage, e.g. to inject
+// failures or delays.
+func WithRepository(wrap func(Repository) Repository) Option {
+	return func(o *options) {
+		o.wrap = wrap
+	}
+}
+
+// Harness is a running order service and its dependencies.
+type Harness struct {
+	Config    *config.Config
+	Settings  *settings.Settings
+	Storage   *memory.Storage
+	SSO       *sso.Server
+	Catalogue *catalogue.Server
+	Events    *events.Bus
+
+	// Orders and Extensions are clients of the service.
+	Orders     orderv20.OrderServiceClient
+	Extensions *orderGrpc.ExtensionClient
+
+	logs  *logBuffer
+	users atomic.Int32
+}
+
+// New starts a harness and stops it when the test ends.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+
+	o := options{identity: identity.Options{Timeout: time.Second}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg := &config.Config{
+		Env:           "local",
+		StorageDriver: "memory",
+		TokenTtl:      time.Hour,
+		GRPC: config.GRPCConfig{
+			Timeout:        10 * time.Second,
+			MaxRecvMsgSize: 4 << 20,
+		},
+		Auth: config.AuthConfig{AdminRole: "admin"},
+		Log:  config.LogConfig{Level: "debug"},
+	}
+	for _, fn := range o.configure {
+		fn(cfg)
+	}
+
+	level := new(slog.LevelVar)
+	h := &Harness{
+		Config:    cfg,
+		Settings:  settings.New(cfg, level),
+		Storage:   memory.New(),
+		SSO:       sso.New(),
+		Catalogue: catalogue.New(),
+		Events:    events.NewBus(),
+		logs:      &logBuffer{},
+	}
+	log := slog.New(slog.NewJSONHandler(h.logs, &slog.HandlerOptions{Level: level}))
+
+	ssoServer, err := fake.Serve(h.SSO.RegisterServices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ssoServer.Close() })
+
+	catalogueServer, err := fake.Serve(h.Catalogue.RegisterServices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = catalogueServer.Close() })
+
+	var repo Repository = h.Storage
+	if o.wrap != nil {
+		repo = o.wrap(repo)
+	}
+
+	identityProvider := identity.New(
+		log,
+		ssov1.NewAuthClient(ssoServer.Conn()),
+		ssov1.NewUserInfoClient(ssoServer.Conn()),
+		o.identity,
+	)
+	orderService := order.New(
+		log,
+		repo,
+		identityProvider,
+		cataloguev20.NewCatalogueServiceClient(catalogueServer.Conn()),
+		h.Settings,
+		h.Events,
+		cfg.TokenTtl,
+	)
+	reportService := report.New(log, repo)
+	importService := importer.New(log, repo, importer.DefaultBatchSize)
+
+	app := grpcapp.New(log, orderService, reportService, importService, identityProvider, h.Settings, cfg.GRPC, cfg.Log.Payloads, insecure.NewCredentials())
+	l := fake.Listen()
+	go func() {
+		_ = app.Serve(l)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = app.Shutdown(ctx)
+	})
+
+	conn, err := fake.Dial(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	h.Orders = orderv20.NewOrderServiceClient(conn)
+	h.Extensions = orderGrpc.NewExtensionClient(conn)
+
+	return h
+}
+
+// Caller is a user known to both SSO and storage, with a valid token.
+type Caller struct {
+	Id    int32
+	Token string
+}
+
+// Context returns ctx carrying the caller's token.
+func (c Caller) Context(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", c.Token)
+}
+
+// AddUser creates a user with the given SSO role and admin flag.
+func (h *Harness) AddUser(role string, admin bool) Caller {
+	email := fmt.Sprintf("user%d@example.com", h.users.Add(1))
+	id := h.Storage.AddUser(email)
+	h.SSO.AddUser(sso.User{Id: int64(id), Email: email, Username: email, Role: role, Admin: admin})
+
+	return Caller{Id: id, Token: h.SSO.IssueToken(int64(id))}
+}
+
+// Admin creates an administrator.
+func (h *Harness) Admin() Caller {
+	return h.AddUser(h.Config.Auth.AdminRole, true)
+}
+
+// Customer creates a user without any privileges.
+func (h *Harness) Customer() Caller {
+	return h.AddUser("user", false)
+}
+
+// AddItem creates an item in both the catalogue and storage.
+func (h *Harness) AddItem(name, description string, price int32) int32 {
+	item := dto.ItemDTO{Name: name, Description: description, Price: price, Quantity: 10}
+	id := h.Storage.AddItem(item)
+	h.Catalogue.AddItem(&cataloguev20.Item{
+		Id:          id,
+		Name:        item.Name,
+		Price:       item.Price,
+		Description: item.Description,
+		Quantity:    item.Quantity,
+	})
+
+	return id
+}
+
+// Logs returns every record the service has logged so far.
+func (h *Harness) Logs() []map[string]interface{} {
+	return h.logs.records()
+}
+
+// logBuffer collects JSON log lines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) records() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			break
+		}
+		records = append(records, r)
+	}
+
+	return records
+}
@@ -0,0 +1,296 @@
+package e2e_test
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/e2e"
+	orderGrpc "github.com/bxiit/order-service-pet-store/internal/grpc/order"
+	"github.com/bxiit/order-service-pet-store/internal/identity"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+// guarded calls every RPC restricted to admins with a request that passes
+// validation.
+func guarded(t *testing.T, h *e2e.Harness, self int32) map[string]func(ctx context.Context) error {
+	report := func(method string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			_, err := h.Extensions.Report(ctx, method, mustStruct(t, map[string]interface{}{}))
+			return err
+		}
+	}
+
+	return map[string]func(ctx context.Context) error{
+		"CancelOrder": func(ctx context.Context) error {
+			_, err := h.Extensions.CancelOrder(ctx, &orderv20.DeleteOrderRequest{Id: 1000})
+			return err
+		},
+		"SetLogLevel": func(ctx context.Context) error {
+			_, err := h.Extensions.SetLogLevel(ctx, wrapperspb.String(""))
+			return err
+		},
+		"SearchOrders": func(ctx context.Context) error {
+			_, err := h.Extensions.SearchOrders(ctx, mustStruct(t, map[string]interface{}{"query": "bowl"}))
+			return err
+		},
+		"SalesReport":      report(orderGrpc.SalesReportMethod),
+		"TopItemsReport":   report(orderGrpc.TopItemsReportMethod),
+		"UserOrdersReport": report(orderGrpc.UserOrdersReportMethod),
+		"ImportOrders": func(ctx context.Context) error {
+			_, err := importOrders(t, h, ctx)
+			return err
+		},
+		"ListOrders": func(ctx context.Context) error {
+			_, err := h.Orders.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+			return err
+		},
+		"GetOrderByUserId": func(ctx context.Context) error {
+			_, err := h.Orders.GetOrderByUserId(ctx, &orderv20.GetOrdersByUserId{UserId: self})
+			return err
+		},
+	}
+}
+
+func TestAdminGuards(t *testing.T) {
+	// want lists the codes for: no token, unknown token, customer, admin.
+	adminOnly := [4]codes.Code{codes.Unauthenticated, codes.Internal, codes.PermissionDenied, codes.OK}
+	want := map[string][4]codes.Code{
+		"CancelOrder":      {codes.Unauthenticated, codes.Internal, codes.PermissionDenied, codes.NotFound},
+		"SetLogLevel":      adminOnly,
+		"SearchOrders":     adminOnly,
+		"SalesReport":      adminOnly,
+		"TopItemsReport":   adminOnly,
+		"UserOrdersReport": adminOnly,
+		"ImportOrders":     adminOnly,
+		"ListOrders":       adminOnly,
+		"GetOrderByUserId": adminOnly,
+	}
+
+	for i, caller := range []string{"no token", "unknown token", "customer", "admin"} {
+		h := e2e.New(t)
+		ctx, self := context.Background(), int32(1)
+		switch caller {
+		case "unknown token":
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "forged")
+		case "customer":
+			c := h.Customer()
+			ctx, self = c.Context(ctx), c.Id
+		case "admin":
+			c := h.Admin()
+			ctx, self = c.Context(ctx), c.Id
+		}
+
+		for method, call := range guarded(t, h, self) {
+			if got := status.Code(call(ctx)); got != want[method][i] {
+				t.Errorf("%s with %s: got %v, want %v", method, caller, got, want[method][i])
+			}
+		}
+	}
+}
+
+func TestAdminGuards_OthersOrders(t *testing.T) {
+	h := e2e.New(t)
+	admin, customer := h.Admin(), h.Customer()
+
+	_, err := h.Orders.GetOrderByUserId(admin.Context(context.Background()), &orderv20.GetOrdersByUserId{UserId: customer.Id})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestAdminGuards_SSOUnavailable(t *testing.T) {
+	h := e2e.New(t)
+	admin := h.Admin()
+	h.SSO.FailWith(status.Error(codes.Unavailable, "down"))
+
+	for method, call := range guarded(t, h, admin.Id) {
+		if got := status.Code(call(admin.Context(context.Background()))); got != codes.Unavailable {
+			t.Errorf("%s: got %v, want Unavailable", method, got)
+		}
+	}
+}
+
+func TestAdminGuards_RoleChange(t *testing.T) {
+	h := e2e.New(t)
+	caller := h.Customer()
+	ctx := caller.Context(context.Background())
+	calls := map[string]func() error{
+		"SetLogLevel": func() error {
+			_, err := h.Extensions.SetLogLevel(ctx, wrapperspb.String(""))
+			return err
+		},
+		"ListOrders": func() error {
+			_, err := h.Orders.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+			return err
+		},
+		"GetOrderByUserId": func() error {
+			_, err := h.Orders.GetOrderByUserId(ctx, &orderv20.GetOrdersByUserId{UserId: caller.Id})
+			return err
+		},
+	}
+
+	for method, call := range calls {
+		if got := status.Code(call()); got != codes.PermissionDenied {
+			t.Errorf("%s before promotion: got %v, want PermissionDenied", method, got)
+		}
+	}
+
+	h.SSO.SetRole(int64(caller.Id), h.Config.Auth.AdminRole, false)
+	for method, call := range calls {
+		if err := call(); err != nil {
+			t.Errorf("%s after promotion: %v", method, err)
+		}
+	}
+
+	// A reload renaming the admin role applies to every guard at once.
+	reloaded := *h.Config
+	reloaded.Auth.AdminRole = "superuser"
+	h.Settings.Apply(&reloaded)
+	for method, call := range calls {
+		if got := status.Code(call()); got != codes.PermissionDenied {
+			t.Errorf("%s after reload: got %v, want PermissionDenied", method, got)
+		}
+	}
+}
+
+func TestIdentityCache(t *testing.T) {
+	h := e2e.New(t, e2e.WithIdentity(identity.Options{Timeout: time.Second, CacheTTL: time.Minute}))
+	ctx := h.Admin().Context(context.Background())
+
+	for i := 0; i < 3; i++ {
+		if _, err := h.Orders.ListOrders(ctx, &orderv20.ListOrdersRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := h.SSO.Calls("GetUserInfo"); n != 1 {
+		t.Errorf("GetUserInfo called %d times, want once", n)
+	}
+	if n := h.SSO.Calls("IsAdmin"); n != 0 {
+		t.Errorf("IsAdmin called %d times; the admin role comes from the user info", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := e2e.New(t,
+		e2e.WithIdentity(identity.Options{Timeout: time.Second, CacheTTL: time.Minute}),
+		e2e.WithConfig(func(cfg *config.Config) {
+			cfg.RateLimits.Methods = map[string]config.RateLimit{"GetOrder": {RPS: 0.001, Burst: 2}}
+		}),
+	)
+	alice, bob := h.Customer(), h.Customer()
+	getOrder := func(caller e2e.Caller) error {
+		_, err := h.Orders.GetOrder(caller.Context(context.Background()), &orderv20.GetOrderRequest{Id: "1"})
+		return err
+	}
+
+	// The limiter only knows callers whose token is cached; until then all
+	// share the peer's bucket. Any authenticated call caches them.
+	for _, caller := range []e2e.Caller{alice, bob} {
+		_, _ = h.Orders.ListOrders(caller.Context(context.Background()), &orderv20.ListOrdersRequest{})
+	}
+
+	for i := 0; i < 2; i++ {
+		wantCode(t, getOrder(alice), codes.NotFound)
+	}
+	wantCode(t, getOrder(alice), codes.ResourceExhausted)
+	wantCode(t, getOrder(bob), codes.NotFound)
+
+	_, err := h.Orders.ListOrders(alice.Context(context.Background()), &orderv20.ListOrdersRequest{})
+	if status.Code(err) == codes.ResourceExhausted {
+		t.Error("the GetOrder limit applied to ListOrders")
+	}
+}
+
+// stallingRepo blocks GetOrderById until the call's context ends.
+type stallingRepo struct {
+	e2e.Repository
+}
+
+func (r stallingRepo) GetOrderById(ctx context.Context, _ int) (*domain.Order, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDeadlines(t *testing.T) {
+	h := e2e.New(t,
+		e2e.WithConfig(func(cfg *config.Config) {
+			cfg.GRPC.MethodTimeouts = map[string]time.Duration{"GetOrder": 20 * time.Millisecond}
+		}),
+		e2e.WithRepository(func(r e2e.Repository) e2e.Repository { return stallingRepo{r} }),
+	)
+
+	start := time.Now()
+	_, err := h.Orders.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: "1"})
+	wantCode(t, err, codes.DeadlineExceeded)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v, the method timeout did not apply", elapsed)
+	}
+}
+
+// panickingRepo panics in GetOrderById.
+type panickingRepo struct {
+	e2e.Repository
+}
+
+func (r panickingRepo) GetOrderById(context.Context, int) (*domain.Order, error) {
+	panic("boom")
+}
+
+func TestRecovery(t *testing.T) {
+	h := e2e.New(t, e2e.WithRepository(func(r e2e.Repository) e2e.Repository { return panickingRepo{r} }))
+	admin := h.Admin()
+
+	_, err := h.Orders.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: "1"})
+	wantCode(t, err, codes.Internal)
+
+	if _, err := h.Orders.ListOrders(admin.Context(context.Background()), &orderv20.ListOrdersRequest{}); err != nil {
+		t.Errorf("server did not survive the panic: %v", err)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	h := e2e.New(t, e2e.WithConfig(func(cfg *config.Config) {
+		cfg.Log.Payloads = true
+	}))
+	admin := h.Admin()
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(admin.Context(context.Background()), "x-request-id", "req-1")
+	_, err := h.Extensions.SearchOrders(ctx, mustStruct(t, map[string]interface{}{"query": "salmon"}), grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("got request id header %v", got)
+	}
+	if _, err := importOrders(t, h, admin.Context(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := map[string]map[string]interface{}{}
+	for _, r := range h.Logs() {
+		if r["msg"] == "rpc finished" {
+			finished[r["method"].(string)] = r
+		}
+	}
+
+	search := finished[orderGrpc.SearchOrdersMethod]
+	if search == nil {
+		t.Fatalf("no access log line for SearchOrders in %v", finished)
+	}
+	if search["code"] != "OK" || search["request_id"] != "req-1" || search["user_id"] != float64(admin.Id) {
+		t.Errorf("got %v", search)
+	}
+	if request, _ := search["request"].(string); request == "" {
+		t.Error("payload not logged")
+	}
+
+	if stream := finished[orderGrpc.ImportOrdersMethod]; stream == nil || stream["user_id"] != float64(admin.Id) {
+		t.Errorf("got stream line %v", stream)
+	}
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Bus is an in-process Publisher. It keeps every event and hands it to the
+// current subscribers, so tests can check what the service published
+// without a broker.
+type Bus struct {
+	mu          sync.Mutex
+	events      [][]byte
+	subscribers map[chan []byte]struct{}
+	err         error
+	closed      bool
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan []byte]struct{})}
+}
+
+// Publish stores a copy of body and delivers it to every subscriber whose
+// buffer has room.
+func (b *Bus) Publish(ctx context.Context, body []byte) error {
+	const op = "events.Bus.Publish"
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+	if b.err != nil {
+		return fmt.Errorf("%s: %w", op, b.err)
+	}
+
+	event := append([]byte(nil), body...)
+	b.events = append(b.events, event)
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel receiving events published from now on, with
+// room for buffer of them. cancel stops the delivery and closes the channel.
+func (b *Bus) Subscribe(buffer int) (events <-chan []byte, cancel func()) {
+	ch := make(chan []byte, buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Events returns every event published so far.
+func (b *Bus) Events() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([][]byte(nil), b.events...)
+}
+
+// FailWith makes Publish fail with err until it is called with nil.
+func (b *Bus) FailWith(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
+
+// Connected reports whether the bus still accepts events.
+func (b *Bus) Connected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.closed
+}
+
+// Close rejects further publishes. Subscribers keep their channels until
+// they cancel.
+func (b *Bus) Close(context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	return nil
+}
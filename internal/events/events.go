@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Publisher delivers order events to whoever listens for them.
+type Publisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+var ErrClosed = errors.New("publisher is closed")
+
+const dialTimeout = 30 * time.Second
+
+// AMQP publishes events to a queue. The connection is opened on the first
+// publish and re-opened after the broker drops it. Close waits for publishes
+// that are still in flight.
+type AMQP struct {
+	log   *slog.Logger
+	url   string
+	queue string
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+func NewAMQP(log *slog.Logger, url, queue string) *AMQP {
+	return &AMQP{
+		log:   log,
+		url:   url,
+		queue: queue,
+	}
+}
+
+func (p *AMQP) Publish(ctx context.Context, body []byte) error {
+	const op = "events.AMQP.Publish"
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return fmt.Errorf("%s: %w", op, ErrClosed)
+	}
+	p.inFlight.Add(1)
+	defer p.inFlight.Done()
+
+	ch, err := p.channel(ctx)
+	p.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = ch.PublishWithContext(
+		ctx,
+		"",
+		p.queue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "text/plain",
+			Body:        body,
+		})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// channel returns an open channel, dialing the broker within ctx when
+// needed. p.mu must be held.
+func (p *AMQP) channel(ctx context.Context) (*amqp.Channel, error) {
+	if p.ch != nil && !p.ch.IsClosed() {
+		return p.ch, nil
+	}
+
+	if p.conn == nil || p.conn.IsClosed() {
+		conn, err := amqp.DialConfig(p.url, amqp.Config{
+			Locale: "en_US",
+			Dial:   dialer(ctx),
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+	}
+
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ch.QueueDeclare(
+		p.queue, // name
+		false,   // durable
+		false,   // delete when unused
+		false,   // exclusive
+		false,   // no-wait
+		nil,     // arguments
+	)
+	if err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+
+	p.ch = ch
+
+	return ch, nil
+}
+
+// dialer connects within ctx and bounds the AMQP handshake by its deadline,
+// or by dialTimeout when ctx has none. The library clears the deadline once
+// the handshake is done.
+func dialer(ctx context.Context) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(dialTimeout)
+		}
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+// Connected reports whether the broker connection is currently open.
+func (p *AMQP) Connected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.conn != nil && !p.conn.IsClosed()
+}
+
+// Close rejects new publishes, waits for in-flight ones until ctx is done and
+// closes the broker connection.
+func (p *AMQP) Close(ctx context.Context) error {
+	const op = "events.AMQP.Close"
+
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.inFlight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("%s: %w", op, ctx.Err())
+		p.log.Warn("closing AMQP connection with publishes in flight")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+// Package export encodes orders as CSV or JSON Lines.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// columns maps every exportable column to the order field it holds.
+var columns = map[string]func(*dto.OrderDTO) interface{}{
+	"id":               func(o *dto.OrderDTO) interface{} { return o.ID },
+	"user_id":          func(o *dto.OrderDTO) interface{} { return o.UserId },
+	"item_id":          func(o *dto.OrderDTO) interface{} { return o.ItemId },
+	"status":           func(o *dto.OrderDTO) interface{} { return o.Status },
+	"created_at":       func(o *dto.OrderDTO) interface{} { return o.CreatedAt.UTC().Format(time.RFC3339) },
+	"item_name":        func(o *dto.OrderDTO) interface{} { return o.Item.Name },
+	"item_price":       func(o *dto.OrderDTO) interface{} { return o.Item.Price },
+	"item_description": func(o *dto.OrderDTO) interface{} { return o.Item.Description },
+	"item_quantity":    func(o *dto.OrderDTO) interface{} { return o.Item.Quantity },
+	"item_image_url":   func(o *dto.OrderDTO) interface{} { return o.Item.ImageURL },
+}
+
+// DefaultColumns are exported when no columns are asked for.
+var DefaultColumns = []string{"id", "user_id", "item_id", "status", "created_at", "item_name", "item_price"}
+
+// ParseColumns splits a comma-separated column list, rejecting unknown and
+// repeated names. An empty list gives DefaultColumns.
+func ParseColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultColumns, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q listed twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// Writer encodes orders one at a time. Close flushes buffered output but
+// leaves the underlying writer open.
+type Writer interface {
+	Write(*dto.OrderDTO) error
+	Close() error
+}
+
+// New returns a Writer encoding the given columns in format to out.
+func New(format string, out io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(out, columns)
+	case FormatJSONL:
+		return newJSONLWriter(out, columns), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+// csvWriter writes a header row followed by one row per order.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+}
+
+func newCSVWriter(out io.Writer, columns []string) (*csvWriter, error) {
+	w := &csvWriter{
+		w:       csv.NewWriter(out),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+	if err := w.w.Write(columns); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *csvWriter) Write(order *dto.OrderDTO) error {
+	for i, name := range w.columns {
+		w.record[i] = field(columns[name](order))
+	}
+
+	return w.w.Write(w.record)
+}
+
+// field formats a column value as a CSV field. Every value is formatted, so
+// a record never keeps a field of the previous order.
+func field(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonlWriter writes one JSON object per line, keys in column order.
+type jsonlWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func newJSONLWriter(out io.Writer, columns []string) *jsonlWriter {
+	return &jsonlWriter{
+		w:       bufio.NewWriter(out),
+		columns: columns,
+	}
+}
+
+func (w *jsonlWriter) Write(order *dto.OrderDTO) error {
+	w.w.WriteByte('{')
+	for i, name := range w.columns {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		value, err := json.Marshal(columns[name](order))
+		if err != nil {
+			return err
+		}
+		w.w.Write(key)
+		w.w.WriteByte(':')
+		w.w.Write(value)
+	}
+	_, err := w.w.WriteString("}\n")
+
+	return err
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.w.Flush()
+}
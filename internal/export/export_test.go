@@ -0,0 +1,99 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var orders = []*dto.OrderDTO{
+	{
+		ID: 1, UserId: 7, ItemId: 3, Status: "created",
+		CreatedAt: time.Date(2024, 3, 15, 10, 0, 0, 0, time.FixedZone("", 3600)),
+		Item:      dto.ItemDTO{ID: 3, Name: "bowl", Price: 500, Description: "steel, \"dishwasher safe\"", Quantity: 10},
+	},
+	{
+		ID: 2, UserId: 8, ItemId: 4, Status: "cancelled",
+		CreatedAt: time.Date(2024, 3, 16, 9, 30, 0, 0, time.UTC),
+		Item:      dto.ItemDTO{ID: 4, Name: "leash", ImageURL: "https://example.com/leash.png"},
+	},
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		golden  string
+		format  string
+		columns string
+	}{
+		{"orders.csv", FormatCSV, ""},
+		{"orders_columns.csv", FormatCSV, "item_description, id,item_image_url"},
+		{"orders.jsonl", FormatJSONL, ""},
+		{"orders_columns.jsonl", FormatJSONL, "item_description, id,item_image_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			columns, err := ParseColumns(tt.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			w, err := New(tt.format, &buf, columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, o := range orders {
+				if err := w.Write(o); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("got\n%s\nwant\n%s", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	for _, list := range []string{"id,nope", "id, id"} {
+		if _, err := ParseColumns(list); err == nil {
+			t.Errorf("%q: no error", list)
+		}
+	}
+}
+
+func TestField(t *testing.T) {
+	tests := map[interface{}]string{
+		"bowl":         "bowl",
+		int32(-5):      "-5",
+		int64(1 << 40): "1099511627776",
+		true:           "true",
+	}
+
+	for v, want := range tests {
+		if got := field(v); got != want {
+			t.Errorf("%v: got %q, want %q", v, got, want)
+		}
+	}
+}
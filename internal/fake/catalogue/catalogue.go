@@ -0,0 +1,145 @@
+// Package catalogue is an in-memory catalogue service with items set up by
+// the test.
+package catalogue
+
+import (
+	"context"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Server is safe for concurrent use. The zero value is not usable; call New.
+type Server struct {
+	cataloguev20.UnimplementedCatalogueServiceServer
+
+	mu     sync.Mutex
+	items  map[int32]*cataloguev20.Item
+	lastId int32
+	err    error
+	calls  map[string]int
+}
+
+func New() *Server {
+	return &Server{
+		items: make(map[int32]*cataloguev20.Item),
+		calls: make(map[string]int),
+	}
+}
+
+// RegisterServices adds the catalogue service to r.
+func (s *Server) RegisterServices(r grpc.ServiceRegistrar) {
+	cataloguev20.RegisterCatalogueServiceServer(r, s)
+}
+
+// AddItem stores a copy of item, or replaces the item with the same id, and
+// returns its id. A zero id is assigned.
+func (s *Server) AddItem(item *cataloguev20.Item) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(item)
+}
+
+func (s *Server) add(item *cataloguev20.Item) int32 {
+	item = proto.Clone(item).(*cataloguev20.Item)
+	if item.Id == 0 {
+		item.Id = s.lastId + 1
+	}
+	s.lastId = max(s.lastId, item.Id)
+	s.items[item.Id] = item
+
+	return item.Id
+}
+
+// RemoveItem deletes the item with the given id.
+func (s *Server) RemoveItem(id int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, id)
+}
+
+// FailWith makes every call fail with err until it is called with nil.
+func (s *Server) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// Calls returns how often the named method, such as "GetItem", was called.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// begin counts a call and returns the programmed failure, if any.
+func (s *Server) begin(method string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+
+	return s.err
+}
+
+func (s *Server) CreateItem(_ context.Context, req *cataloguev20.CreateItemRequest) (*cataloguev20.CreateItemResponse, error) {
+	if err := s.begin("CreateItem"); err != nil {
+		return nil, err
+	}
+	if req.GetItem() == nil {
+		return nil, status.Error(codes.InvalidArgument, "item is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := proto.Clone(req.GetItem()).(*cataloguev20.Item)
+	item.Id = 0
+	item.Id = s.add(item)
+
+	return &cataloguev20.CreateItemResponse{Item: item}, nil
+}
+
+// ListItems returns every item ordered by id.
+func (s *Server) ListItems(context.Context, *cataloguev20.ListItemsRequest) (*cataloguev20.ListItemsResponse, error) {
+	if err := s.begin("ListItems"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]*cataloguev20.Item, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, proto.Clone(item).(*cataloguev20.Item))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+
+	return &cataloguev20.ListItemsResponse{Items: items}, nil
+}
+
+func (s *Server) GetItem(_ context.Context, req *cataloguev20.GetItemRequest) (*cataloguev20.GetItemResponse, error) {
+	if err := s.begin("GetItem"); err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "id must be a number")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[int32(id)]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "item not found")
+	}
+
+	return &cataloguev20.GetItemResponse{Item: proto.Clone(item).(*cataloguev20.Item)}, nil
+}
@@ -0,0 +1,211 @@
+// Package sso is an in-memory SSO service implementing the Auth and
+// UserInfo APIs, with users, roles and tokens set up by the test.
+package sso
+
+import (
+	"context"
+	"fmt"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// User is an SSO account. Admin is what IsAdmin answers; Role is what
+// GetUserInfo reports.
+type User struct {
+	Id       int64
+	Email    string
+	Username string
+	Password string
+	Role     string
+	Admin    bool
+}
+
+// Server is safe for concurrent use. The zero value is not usable; call New.
+type Server struct {
+	ssov1.UnimplementedAuthServer
+	ssov1.UnimplementedUserInfoServer
+
+	mu     sync.Mutex
+	users  map[int64]User
+	tokens map[string]int64
+	lastId int64
+	issued int
+	err    error
+	calls  map[string]int
+}
+
+func New() *Server {
+	return &Server{
+		users:  make(map[int64]User),
+		tokens: make(map[string]int64),
+		calls:  make(map[string]int),
+	}
+}
+
+// RegisterServices adds the Auth and UserInfo services to r.
+func (s *Server) RegisterServices(r grpc.ServiceRegistrar) {
+	ssov1.RegisterAuthServer(r, s)
+	ssov1.RegisterUserInfoServer(r, s)
+}
+
+// AddUser stores u, or replaces the user with the same id, and returns its
+// id. A zero id is assigned.
+func (s *Server) AddUser(u User) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.Id == 0 {
+		u.Id = s.lastId + 1
+	}
+	s.lastId = max(s.lastId, u.Id)
+	s.users[u.Id] = u
+
+	return u.Id
+}
+
+// SetRole changes the role and admin flag of a user.
+func (s *Server) SetRole(userId int64, role string, admin bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.users[userId]
+	u.Role, u.Admin = role, admin
+	s.users[userId] = u
+}
+
+// IssueToken returns a new token of the user, as Login would.
+func (s *Server) IssueToken(userId int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issue(userId)
+}
+
+func (s *Server) issue(userId int64) string {
+	s.issued++
+	token := fmt.Sprintf("token-%d-%d", userId, s.issued)
+	s.tokens[token] = userId
+
+	return token
+}
+
+// RevokeToken makes token unknown.
+func (s *Server) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, token)
+}
+
+// FailWith makes every call fail with err until it is called with nil.
+func (s *Server) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// Calls returns how often the named method, such as "IsAdmin", was called.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// begin counts a call and returns the programmed failure, if any.
+func (s *Server) begin(method string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+
+	return s.err
+}
+
+func (s *Server) Register(_ context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
+	if err := s.begin("Register"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == req.GetEmail() {
+			return nil, status.Error(codes.AlreadyExists, "user already exists")
+		}
+	}
+	s.lastId++
+	s.users[s.lastId] = User{Id: s.lastId, Email: req.GetEmail(), Password: req.GetPassword()}
+
+	return &ssov1.RegisterResponse{UserId: s.lastId}, nil
+}
+
+func (s *Server) Login(_ context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
+	if err := s.begin("Login"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == req.GetEmail() && u.Password == req.GetPassword() {
+			return &ssov1.LoginResponse{Token: s.issue(u.Id)}, nil
+		}
+	}
+
+	return nil, status.Error(codes.InvalidArgument, "invalid email or password")
+}
+
+func (s *Server) IsAdmin(_ context.Context, req *ssov1.IsAdminRequest) (*ssov1.IsAdminResponse, error) {
+	if err := s.begin("IsAdmin"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[req.GetUserId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	return &ssov1.IsAdminResponse{IsAdmin: u.Admin}, nil
+}
+
+func (s *Server) IsAuthenticated(_ context.Context, req *ssov1.IsAuthenticatedRequest) (*ssov1.IsAuthenticatedResponse, error) {
+	if err := s.begin("IsAuthenticated"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.tokens[req.GetToken()]
+
+	return &ssov1.IsAuthenticatedResponse{IsAuthenticated: ok}, nil
+}
+
+// GetUserInfo answers an unknown token with an empty response, which the
+// identity client turns into codes.Unauthenticated.
+func (s *Server) GetUserInfo(_ context.Context, req *ssov1.GetUserInfoRequest) (*ssov1.GetUserInfoResponse, error) {
+	if err := s.begin("GetUserInfo"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.tokens[req.GetToken()]
+	if !ok {
+		return &ssov1.GetUserInfoResponse{}, nil
+	}
+	u := s.users[id]
+
+	return &ssov1.GetUserInfoResponse{User: &ssov1.User{
+		Id:       int32(u.Id),
+		Username: u.Username,
+		Email:    u.Email,
+		Role:     u.Role,
+	}}, nil
+}
@@ -0,0 +1,246 @@
+package orderGrpc
+
+import (
+	"context"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The order.proto published in bxiit/protos does not declare every RPC this
+// service offers yet. The extra methods below are appended to the generated
+// order.OrderService descriptor and reuse the messages that already exist, so
+// any gRPC client can call them by their full method name.
+
+const (
+	CancelOrderMethod  = "/order.OrderService/CancelOrder"
+	SetLogLevelMethod  = "/order.OrderService/SetLogLevel"
+	SearchOrdersMethod = "/order.OrderService/SearchOrders"
+
+	SalesReportMethod      = "/order.OrderService/SalesReport"
+	TopItemsReportMethod   = "/order.OrderService/TopItemsReport"
+	UserOrdersReportMethod = "/order.OrderService/UserOrdersReport"
+
+	ImportOrdersMethod = "/order.OrderService/ImportOrders"
+)
+
+var serviceDesc = extendServiceDesc(orderv20.OrderService_ServiceDesc, []grpc.StreamDesc{
+	{
+		StreamName:    "ImportOrders",
+		Handler:       _OrderService_ImportOrders_Handler,
+		ClientStreams: true,
+	},
+},
+	grpc.MethodDesc{
+		MethodName: "CancelOrder",
+		Handler:    _OrderService_CancelOrder_Handler,
+	},
+	grpc.MethodDesc{
+		MethodName: "SetLogLevel",
+		Handler:    _OrderService_SetLogLevel_Handler,
+	},
+	grpc.MethodDesc{
+		MethodName: "SearchOrders",
+		Handler:    _OrderService_SearchOrders_Handler,
+	},
+	grpc.MethodDesc{
+		MethodName: "SalesReport",
+		Handler:    reportHandler(SalesReportMethod, (*orderService).SalesReport),
+	},
+	grpc.MethodDesc{
+		MethodName: "TopItemsReport",
+		Handler:    reportHandler(TopItemsReportMethod, (*orderService).TopItemsReport),
+	},
+	grpc.MethodDesc{
+		MethodName: "UserOrdersReport",
+		Handler:    reportHandler(UserOrdersReportMethod, (*orderService).UserOrdersReport),
+	},
+)
+
+func extendServiceDesc(desc grpc.ServiceDesc, streams []grpc.StreamDesc, methods ...grpc.MethodDesc) grpc.ServiceDesc {
+	desc.Methods = append(append([]grpc.MethodDesc(nil), desc.Methods...), methods...)
+	desc.Streams = append(append([]grpc.StreamDesc(nil), desc.Streams...), streams...)
+	return desc
+}
+
+func _OrderService_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(orderv20.DeleteOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*orderService).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CancelOrderMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*orderService).CancelOrder(ctx, req.(*orderv20.DeleteOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*orderService).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SetLogLevelMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*orderService).SetLogLevel(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_SearchOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*orderService).SearchOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchOrdersMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*orderService).SearchOrders(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// reportHandler builds the method handler of a report RPC; they all take and
+// return a structpb.Struct.
+func reportHandler(fullMethod string, report func(*orderService, context.Context, *structpb.Struct) (*structpb.Struct, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return report(srv.(*orderService), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fullMethod,
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return report(srv.(*orderService), ctx, req.(*structpb.Struct))
+		}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+func _OrderService_ImportOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*orderService).ImportOrders(&importOrdersServer{stream})
+}
+
+// importOrdersServer is the server side of an ImportOrders stream: rows come
+// in as structpb.Struct and a single report goes back.
+type importOrdersServer struct {
+	grpc.ServerStream
+}
+
+func (x *importOrdersServer) SendAndClose(m *structpb.Struct) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *importOrdersServer) Recv() (*structpb.Struct, error) {
+	m := new(structpb.Struct)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExtensionClient calls the order.OrderService methods that are missing from
+// the generated orderv20.OrderServiceClient.
+type ExtensionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExtensionClient(cc grpc.ClientConnInterface) *ExtensionClient {
+	return &ExtensionClient{cc: cc}
+}
+
+func (c *ExtensionClient) CancelOrder(ctx context.Context, in *orderv20.DeleteOrderRequest, opts ...grpc.CallOption) (*orderv20.DeleteOrderResponse, error) {
+	out := new(orderv20.DeleteOrderResponse)
+	err := c.cc.Invoke(ctx, CancelOrderMethod, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetLogLevel changes the service log level to one of debug, info, warn or
+// error and returns the level in effect. An empty value only reads it.
+func (c *ExtensionClient) SetLogLevel(ctx context.Context, in *wrapperspb.StringValue, opts ...grpc.CallOption) (*wrapperspb.StringValue, error) {
+	out := new(wrapperspb.StringValue)
+	err := c.cc.Invoke(ctx, SetLogLevelMethod, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchOrders runs a full-text search over ordered items. The request holds
+// "query" and optionally "user_id", "from", "to" (RFC 3339), "limit" and
+// "offset"; the total match count comes back in the x-total-count header.
+func (c *ExtensionClient) SearchOrders(ctx context.Context, in *structpb.Struct, opts ...grpc.CallOption) (*orderv20.ListOrdersResponse, error) {
+	out := new(orderv20.ListOrdersResponse)
+	err := c.cc.Invoke(ctx, SearchOrdersMethod, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Report calls one of the report RPCs, such as SalesReportMethod.
+func (c *ExtensionClient) Report(ctx context.Context, method string, in *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	err := c.cc.Invoke(ctx, method, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImportOrders opens a bulk import. Send one struct per order with "user_id",
+// "item_id" and optionally "status" and "created_at", then CloseAndRecv for
+// the report. Set the x-dry-run metadata to "true" to only validate.
+func (c *ExtensionClient) ImportOrders(ctx context.Context, opts ...grpc.CallOption) (*ImportOrdersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &grpc.StreamDesc{StreamName: "ImportOrders", ClientStreams: true}, ImportOrdersMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportOrdersClient{stream}, nil
+}
+
+// ImportOrdersClient is the client side of an ImportOrders stream.
+type ImportOrdersClient struct {
+	grpc.ClientStream
+}
+
+func (x *ImportOrdersClient) Send(m *structpb.Struct) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *ImportOrdersClient) CloseAndRecv() (*structpb.Struct, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(structpb.Struct)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,139 @@
+package orderGrpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+	"strconv"
+)
+
+// MetadataDryRun set to "true" makes ImportOrders validate the rows without
+// storing them.
+const MetadataDryRun = "x-dry-run"
+
+// MetadataImported and MetadataProcessed are trailers of a failed
+// ImportOrders call: how many rows were committed before it failed and the
+// last row whose outcome is final, after which a retry should resume.
+const (
+	MetadataImported  = "x-imported"
+	MetadataProcessed = "x-processed"
+)
+
+// maxImportRows bounds a single ImportOrders stream.
+const maxImportRows = 1_000_000
+
+// maxDetailErrors bounds the row errors in the report attached to a failed
+// import, which travels in the trailers.
+const maxDetailErrors = 100
+
+// Importer loads orders in bulk.
+type Importer interface {
+	Import(ctx context.Context, src importer.Source, dryRun bool) (*importer.Report, error)
+}
+
+// ImportOrders stores the streamed orders and answers with the import
+// report: row counts and one error per rejected row. When the import fails
+// midway the batches committed so far stay; the partial report is then
+// attached to the error status and summed up in the trailers.
+func (os *orderService) ImportOrders(stream *importOrdersServer) error {
+	ctx := stream.Context()
+
+	dryRun := false
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataDryRun); len(v) > 0 {
+			dryRun = v[0] == "true"
+		}
+	}
+
+	src := &streamSource{stream: stream}
+	report, err := os.importer.Import(ctx, src, dryRun)
+	if err != nil {
+		st := status.New(codes.Internal, "failed to import orders")
+		if src.err != nil {
+			st = status.Convert(src.err)
+		}
+		return importError(stream, st, report)
+	}
+
+	resp, err := reportToStruct(report)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to encode import report")
+	}
+
+	return stream.SendAndClose(resp)
+}
+
+// importError returns st with the partial report as a detail, and sets the
+// trailers summing it up.
+func importError(stream *importOrdersServer, st *status.Status, report *importer.Report) error {
+	if report == nil {
+		return st.Err()
+	}
+
+	stream.SetTrailer(metadata.Pairs(
+		MetadataImported, strconv.Itoa(report.Imported),
+		MetadataProcessed, strconv.Itoa(report.Processed),
+	))
+
+	partial := *report
+	if len(partial.Errors) > maxDetailErrors {
+		partial.Errors = partial.Errors[:maxDetailErrors]
+		partial.ErrorsTruncated = true
+	}
+	detail, err := reportToStruct(&partial)
+	if err != nil {
+		return st.Err()
+	}
+	withReport, err := st.WithDetails(detail)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withReport.Err()
+}
+
+// streamSource reads import rows off the stream. err keeps a failed receive
+// so its status reaches the client unchanged.
+type streamSource struct {
+	stream *importOrdersServer
+	line   int
+	err    error
+}
+
+func (s *streamSource) Next() (importer.Row, error) {
+	msg, err := s.stream.Recv()
+	if err == io.EOF {
+		return importer.Row{}, io.EOF
+	}
+	if err != nil {
+		s.err = err
+		return importer.Row{}, err
+	}
+
+	s.line++
+	if s.line > maxImportRows {
+		s.err = status.Errorf(codes.InvalidArgument, "an import takes at most %d rows", maxImportRows)
+		return importer.Row{}, s.err
+	}
+
+	return importer.RowFromFields(s.line, msg.AsMap()), nil
+}
+
+func reportToStruct(report *importer.Report) (*structpb.Struct, error) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewStruct(fields)
+}
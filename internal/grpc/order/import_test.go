@@ -0,0 +1,142 @@
+package orderGrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/services/importer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+	"testing"
+)
+
+// fakeImportStream hands out rows and then recvErr, and records what the
+// handler sent back.
+type fakeImportStream struct {
+	grpc.ServerStream
+	rows    []*structpb.Struct
+	recvErr error
+	sent    *structpb.Struct
+	trailer metadata.MD
+}
+
+func (s *fakeImportStream) Context() context.Context { return context.Background() }
+
+func (s *fakeImportStream) RecvMsg(m interface{}) error {
+	if len(s.rows) == 0 {
+		return s.recvErr
+	}
+	proto.Merge(m.(*structpb.Struct), s.rows[0])
+	s.rows = s.rows[1:]
+	return nil
+}
+
+func (s *fakeImportStream) SendMsg(m interface{}) error {
+	s.sent = m.(*structpb.Struct)
+	return nil
+}
+
+func (s *fakeImportStream) SetTrailer(md metadata.MD) { s.trailer = md }
+
+// fakeImporter reads every row and fails with err once the input ends or
+// breaks, after reporting the rows it has seen as imported.
+type fakeImporter struct {
+	err    error
+	errors int
+}
+
+func (f *fakeImporter) Import(_ context.Context, src importer.Source, dryRun bool) (*importer.Report, error) {
+	report := &importer.Report{DryRun: dryRun}
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+		report.Imported++
+		report.Processed = row.Line
+	}
+	for i := 0; i < f.errors; i++ {
+		report.Errors = append(report.Errors, &importer.RowError{Line: i + 1, Message: "bad"})
+	}
+	return report, f.err
+}
+
+func importRows(n int) []*structpb.Struct {
+	rows := make([]*structpb.Struct, n)
+	for i := range rows {
+		rows[i], _ = structpb.NewStruct(map[string]interface{}{"user_id": 1, "item_id": 2})
+	}
+	return rows
+}
+
+func TestImportOrders(t *testing.T) {
+	stream := &fakeImportStream{rows: importRows(2), recvErr: io.EOF}
+	srv := &orderService{importer: &fakeImporter{}}
+
+	if err := srv.ImportOrders(&importOrdersServer{stream}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stream.sent.GetFields()["imported"].GetNumberValue(); got != 2 {
+		t.Errorf("got report %v", stream.sent)
+	}
+	if stream.trailer != nil {
+		t.Errorf("got trailer %v", stream.trailer)
+	}
+}
+
+func TestImportOrders_Partial(t *testing.T) {
+	tests := []struct {
+		name     string
+		recvErr  error
+		importer *fakeImporter
+		want     codes.Code
+	}{
+		{"receive failed", status.Error(codes.Canceled, "client went away"), &fakeImporter{}, codes.Canceled},
+		{"database failed", io.EOF, &fakeImporter{err: errors.New("connection refused"), errors: maxDetailErrors + 5}, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &fakeImportStream{rows: importRows(3), recvErr: tt.recvErr}
+			srv := &orderService{importer: tt.importer}
+
+			err := srv.ImportOrders(&importOrdersServer{stream})
+			st := status.Convert(err)
+			if st.Code() != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+
+			if got := stream.trailer.Get(MetadataImported); len(got) != 1 || got[0] != "3" {
+				t.Errorf("imported trailer: got %v", got)
+			}
+			if got := stream.trailer.Get(MetadataProcessed); len(got) != 1 || got[0] != "3" {
+				t.Errorf("processed trailer: got %v", got)
+			}
+
+			if len(st.Details()) != 1 {
+				t.Fatalf("got details %v", st.Details())
+			}
+			report, ok := st.Details()[0].(*structpb.Struct)
+			if !ok {
+				t.Fatalf("got detail %T", st.Details()[0])
+			}
+			if got := report.GetFields()["imported"].GetNumberValue(); got != 3 {
+				t.Errorf("got report %v", report)
+			}
+			if got := len(report.GetFields()["errors"].GetListValue().GetValues()); got > maxDetailErrors {
+				t.Errorf("got %d errors in the detail", got)
+			}
+			if tt.importer.errors > maxDetailErrors && !report.GetFields()["errors_truncated"].GetBoolValue() {
+				t.Error("errors_truncated not set")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package orderGrpc
+
+import (
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+)
+
+// orderFromProto converts an API order into a new domain order of its one
+// item. The item details the client sent are kept, with item_id as the id.
+func orderFromProto(o *orderv20.Order) *domain.Order {
+	item := itemFromProto(o.GetItem())
+	item.ID = o.GetItemId()
+
+	order := domain.NewOrder(o.GetUserId(), item)
+	order.ID = o.GetId()
+
+	return order
+}
+
+// orderToProto converts a domain order into an API order, which holds a
+// single item: the one of the first line.
+func orderToProto(o *domain.Order) *orderv20.Order {
+	resp := &orderv20.Order{
+		Id:     o.ID,
+		UserId: o.UserId,
+	}
+	if len(o.Lines) > 0 {
+		resp.ItemId = o.Lines[0].Item.ID
+		resp.Item = itemToProto(o.Lines[0].Item)
+	}
+	return resp
+}
+
+func ordersToProto(orders []*domain.Order) []*orderv20.Order {
+	resp := make([]*orderv20.Order, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, orderToProto(o))
+	}
+	return resp
+}
+
+func itemFromProto(item *cataloguev20.Item) domain.Item {
+	return domain.Item{
+		ID:          item.GetId(),
+		Name:        item.GetName(),
+		Description: item.GetDescription(),
+		ImageURL:    item.GetImageUrl(),
+		Price:       item.GetPrice(),
+		Quantity:    item.GetQuantity(),
+	}
+}
+
+func itemToProto(item domain.Item) *cataloguev20.Item {
+	return &cataloguev20.Item{
+		Id:          item.ID,
+		Name:        item.Name,
+		Price:       item.Price,
+		Description: item.Description,
+		Quantity:    item.Quantity,
+		ImageUrl:    item.ImageURL,
+	}
+}
@@ -0,0 +1,182 @@
+package orderGrpc
+
+import (
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"github.com/jinzhu/copier"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func fullOrder() *orderv20.Order {
+	return &orderv20.Order{
+		Id:     9,
+		ItemId: 3,
+		UserId: 2,
+		Item: &cataloguev20.Item{
+			Id:          3,
+			Name:        "bowl",
+			Price:       450,
+			Description: "steel",
+			Quantity:    2,
+			ImageUrl:    "https://img/bowl.png",
+		},
+	}
+}
+
+// assertAllSet fails when a field of m is unset, so a field added to the
+// proto can't be silently left out of the mappers.
+func assertAllSet(t *testing.T, m proto.Message) {
+	t.Helper()
+
+	var check func(protoreflect.Message)
+	check = func(msg protoreflect.Message) {
+		fields := msg.Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if !msg.Has(fd) {
+				t.Errorf("%s is not set", fd.FullName())
+				continue
+			}
+			if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+				check(msg.Get(fd).Message())
+			}
+		}
+	}
+	check(m.ProtoReflect())
+}
+
+func TestOrderRoundTrip(t *testing.T) {
+	in := fullOrder()
+	assertAllSet(t, in)
+
+	out := orderToProto(orderFromProto(in))
+	if !proto.Equal(in, out) {
+		t.Errorf("got %v, want %v", out, in)
+	}
+}
+
+func TestOrderFromProto(t *testing.T) {
+	got := orderFromProto(fullOrder())
+	want := &domain.Order{
+		ID:     9,
+		UserId: 2,
+		Status: domain.StatusCreated,
+		Lines: []domain.Line{{
+			Item:     domain.Item{ID: 3, Name: "bowl", Price: 450, Description: "steel", Quantity: 2, ImageURL: "https://img/bowl.png"},
+			Quantity: 1,
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// item_id wins over the id of the item details.
+	in := fullOrder()
+	in.Item.Id = 4
+	if got := orderFromProto(in).ItemIds(); !reflect.DeepEqual(got, []int32{3}) {
+		t.Errorf("got items %v, want [3]", got)
+	}
+
+	if got := orderFromProto(nil); got.UserId != 0 || !reflect.DeepEqual(got.ItemIds(), []int32{0}) {
+		t.Errorf("nil order: got %+v", got)
+	}
+}
+
+func TestOrderToProto(t *testing.T) {
+	order := &domain.Order{
+		ID:        9,
+		UserId:    2,
+		Status:    domain.StatusCancelled,
+		CreatedAt: time.Now(),
+		Lines: []domain.Line{
+			{Item: domain.Item{ID: 3, Name: "bowl"}, Quantity: 1},
+			{Item: domain.Item{ID: 4, Name: "bed"}, Quantity: 1},
+		},
+	}
+
+	got := ordersToProto([]*domain.Order{order, {ID: 10, UserId: 2}})
+	want := []*orderv20.Order{
+		{Id: 9, UserId: 2, ItemId: 3, Item: &cataloguev20.Item{Id: 3, Name: "bowl"}},
+		{Id: 10, UserId: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("order %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func benchmarkOrders() []*domain.Order {
+	orders := make([]*domain.Order, 100)
+	for i := range orders {
+		orders[i] = orderFromProto(fullOrder())
+		orders[i].ID = int32(i + 1)
+	}
+	return orders
+}
+
+func BenchmarkOrdersToProto(b *testing.B) {
+	orders := benchmarkOrders()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = ordersToProto(orders)
+	}
+}
+
+// BenchmarkOrdersToProto_Copier is what the handlers did before the typed
+// mappers, copying storage rows, kept for comparison.
+func BenchmarkOrdersToProto_Copier(b *testing.B) {
+	var orders []*dto.OrderDTO
+	for _, o := range benchmarkOrders() {
+		row, err := dto.FromDomain(o)
+		if err != nil {
+			b.Fatal(err)
+		}
+		orders = append(orders, row)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp := make([]*orderv20.Order, 0, len(orders))
+		for _, order := range orders {
+			var o orderv20.Order
+			if err := copier.Copy(&o, order); err != nil {
+				b.Fatal(err)
+			}
+			resp = append(resp, &o)
+		}
+	}
+}
+
+func BenchmarkOrderFromProto(b *testing.B) {
+	order := fullOrder()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = orderFromProto(order)
+	}
+}
+
+func BenchmarkOrderFromProto_Copier(b *testing.B) {
+	order := fullOrder()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var o dto.OrderDTO
+		if err := copier.Copy(&o, order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
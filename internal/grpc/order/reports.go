@@ -0,0 +1,109 @@
+package orderGrpc
+
+import (
+	"context"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ReportService builds the admin sales reports.
+type ReportService interface {
+	SalesByPeriod(context.Context, models.ReportFilter) ([]models.PeriodSales, error)
+	TopItems(context.Context, models.ReportFilter) ([]models.ItemSales, error)
+	SalesByUser(context.Context, models.ReportFilter) ([]models.UserSales, error)
+}
+
+// SalesReport answers {"periods": [...], "total": {...}} with orders, revenue
+// and average order value per day, week or month.
+func (os *orderService) SalesReport(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	filter, err := reportFilterFromStruct(req, "granularity")
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := os.reports.SalesByPeriod(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to build sales report")
+	}
+
+	var total models.Sales
+	rows := make([]interface{}, 0, len(periods))
+	for _, p := range periods {
+		row := salesFields(p.Sales)
+		row["period"] = p.Period.Format("2006-01-02")
+		rows = append(rows, row)
+
+		total.Orders += p.Orders
+		total.Revenue += p.Revenue
+	}
+
+	return reportResponse(map[string]interface{}{
+		"periods": rows,
+		"total":   salesFields(total),
+	})
+}
+
+// TopItemsReport answers {"items": [...]} with the best selling items by
+// quantity or revenue.
+func (os *orderService) TopItemsReport(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	filter, err := reportFilterFromStruct(req, "limit", "by")
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := os.reports.TopItems(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to build top items report")
+	}
+
+	rows := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		row := salesFields(item.Sales)
+		row["item_id"] = float64(item.ItemId)
+		row["name"] = item.Name
+		rows = append(rows, row)
+	}
+
+	return reportResponse(map[string]interface{}{"items": rows})
+}
+
+// UserOrdersReport answers {"users": [...]} with the users placing the most
+// orders.
+func (os *orderService) UserOrdersReport(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	filter, err := reportFilterFromStruct(req, "limit")
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := os.reports.SalesByUser(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to build user orders report")
+	}
+
+	rows := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		row := salesFields(user.Sales)
+		row["user_id"] = float64(user.UserId)
+		rows = append(rows, row)
+	}
+
+	return reportResponse(map[string]interface{}{"users": rows})
+}
+
+func salesFields(s models.Sales) map[string]interface{} {
+	return map[string]interface{}{
+		"orders":              float64(s.Orders),
+		"revenue":             float64(s.Revenue),
+		"average_order_value": s.AverageOrderValue(),
+	}
+}
+
+func reportResponse(fields map[string]interface{}) (*structpb.Struct, error) {
+	resp, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encode report")
+	}
+	return resp, nil
+}
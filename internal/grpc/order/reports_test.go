@@ -0,0 +1,164 @@
+package orderGrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeReports struct {
+	periods []models.PeriodSales
+	items   []models.ItemSales
+	users   []models.UserSales
+	err     error
+	filter  models.ReportFilter
+}
+
+func (f *fakeReports) SalesByPeriod(_ context.Context, filter models.ReportFilter) ([]models.PeriodSales, error) {
+	f.filter = filter
+	return f.periods, f.err
+}
+
+func (f *fakeReports) TopItems(_ context.Context, filter models.ReportFilter) ([]models.ItemSales, error) {
+	f.filter = filter
+	return f.items, f.err
+}
+
+func (f *fakeReports) SalesByUser(_ context.Context, filter models.ReportFilter) ([]models.UserSales, error) {
+	f.filter = filter
+	return f.users, f.err
+}
+
+func newStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSalesReport(t *testing.T) {
+	reports := &fakeReports{periods: []models.PeriodSales{
+		{Period: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Sales: models.Sales{Orders: 2, Revenue: 900}},
+		{Period: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Sales: models.Sales{Orders: 1, Revenue: 300}},
+	}}
+	srv := &orderService{reports: reports}
+
+	resp, err := srv.SalesReport(context.Background(), newStruct(t, map[string]interface{}{
+		"from":        "2024-01-01T00:00:00Z",
+		"to":          "2024-02-01T00:00:00Z",
+		"timezone":    "Asia/Almaty",
+		"granularity": "week",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFilter := models.ReportFilter{
+		From:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Timezone:    "Asia/Almaty",
+		Granularity: models.GranularityWeek,
+		Limit:       defaultReportLimit,
+		By:          models.ByQuantity,
+	}
+	if reports.filter != wantFilter {
+		t.Errorf("got filter %+v, want %+v", reports.filter, wantFilter)
+	}
+
+	want := map[string]interface{}{
+		"periods": []interface{}{
+			map[string]interface{}{"period": "2024-01-01", "orders": 2.0, "revenue": 900.0, "average_order_value": 450.0},
+			map[string]interface{}{"period": "2024-01-08", "orders": 1.0, "revenue": 300.0, "average_order_value": 300.0},
+		},
+		"total": map[string]interface{}{"orders": 3.0, "revenue": 1200.0, "average_order_value": 400.0},
+	}
+	if got := resp.AsMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTopItemsReport(t *testing.T) {
+	reports := &fakeReports{items: []models.ItemSales{
+		{ItemId: 3, Name: "bowl", Sales: models.Sales{Orders: 4, Revenue: 1800}},
+	}}
+	srv := &orderService{reports: reports}
+
+	resp, err := srv.TopItemsReport(context.Background(), newStruct(t, map[string]interface{}{"limit": 3, "by": "revenue"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports.filter.Limit != 3 || reports.filter.By != models.ByRevenue {
+		t.Errorf("got filter %+v", reports.filter)
+	}
+
+	want := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"item_id": 3.0, "name": "bowl", "orders": 4.0, "revenue": 1800.0, "average_order_value": 450.0},
+	}}
+	if got := resp.AsMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUserOrdersReport(t *testing.T) {
+	reports := &fakeReports{users: []models.UserSales{
+		{UserId: 2, Sales: models.Sales{Orders: 0}},
+	}}
+	srv := &orderService{reports: reports}
+
+	resp, err := srv.UserOrdersReport(context.Background(), newStruct(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"users": []interface{}{
+		map[string]interface{}{"user_id": 2.0, "orders": 0.0, "revenue": 0.0, "average_order_value": 0.0},
+	}}
+	if got := resp.AsMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReports_Errors(t *testing.T) {
+	type report func(*orderService, context.Context, *structpb.Struct) (*structpb.Struct, error)
+	reportsByName := map[string]report{
+		"sales":     (*orderService).SalesReport,
+		"top items": (*orderService).TopItemsReport,
+		"users":     (*orderService).UserOrdersReport,
+	}
+
+	tests := []struct {
+		name   string
+		report string
+		fields map[string]interface{}
+		err    error
+		want   codes.Code
+	}{
+		{"unknown field", "sales", map[string]interface{}{"limit": 3}, nil, codes.InvalidArgument},
+		{"granularity", "sales", map[string]interface{}{"granularity": "hour"}, nil, codes.InvalidArgument},
+		{"timezone", "sales", map[string]interface{}{"timezone": "Mars/Olympus"}, nil, codes.InvalidArgument},
+		{"local timezone", "users", map[string]interface{}{"timezone": "Local"}, nil, codes.InvalidArgument},
+		{"empty range", "sales", map[string]interface{}{"from": "2024-02-01T00:00:00Z", "to": "2024-01-01T00:00:00Z"}, nil, codes.InvalidArgument},
+		{"by", "top items", map[string]interface{}{"by": "price"}, nil, codes.InvalidArgument},
+		{"limit", "top items", map[string]interface{}{"limit": 0}, nil, codes.InvalidArgument},
+		{"by on users", "users", map[string]interface{}{"by": "revenue"}, nil, codes.InvalidArgument},
+		{"sales failure", "sales", nil, errors.New("boom"), codes.Internal},
+		{"top items failure", "top items", nil, errors.New("boom"), codes.Internal},
+		{"users failure", "users", nil, errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &orderService{reports: &fakeReports{err: tt.err}}
+
+			_, err := reportsByName[tt.report](srv, context.Background(), newStruct(t, tt.fields))
+			assertCode(t, err, tt.want)
+		})
+	}
+}
@@ -2,123 +2,181 @@ package orderGrpc
 
 import (
 	"context"
-	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data"
 	"github.com/bxiit/order-service-pet-store/internal/data/models"
-	"github.com/bxiit/protos/gen/go/catalogue"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
 	orderv20 "github.com/bxiit/protos/gen/go/order"
-	"github.com/jinzhu/copier"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"log"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"log/slog"
 	"strconv"
+	"strings"
 )
 
 type OrderService interface {
-	CreateOrder(context.Context, *dto.OrderDTO) (*dto.OrderDTO, error)
-	ListOrders(context.Context) ([]*models.Order, error)
-	GetOrder(context.Context, int) (*models.Order, error)
-	GetOrdersByUserId(context.Context, int) ([]*dto.OrderDTO, error)
+	CreateOrder(context.Context, *domain.Order) (*domain.Order, error)
+	ListOrders(context.Context, models.OrderFilter) ([]*domain.Order, error)
+	GetOrder(context.Context, int) (*domain.Order, error)
+	GetOrdersByUserId(context.Context, int) ([]*domain.Order, error)
+	CancelOrder(context.Context, int) error
+	SearchOrders(context.Context, models.SearchFilter) ([]*domain.Order, int, error)
+}
+
+// Metadata keys carrying the optional ListOrders filters. ListOrdersRequest has
+// no fields, so the filters travel next to it; the REST gateway fills them
+// from query parameters.
+const (
+	MetadataListUserId = "x-list-user-id"
+	MetadataListStatus = "x-list-status"
+	MetadataListLimit  = "x-list-limit"
+	MetadataListOffset = "x-list-offset"
+)
+
+// MetadataTotalCount is the response header carrying the number of orders a
+// search matched before pagination.
+const MetadataTotalCount = "x-total-count"
+
+// LogLevel is the runtime log level exposed through SetLogLevel.
+type LogLevel interface {
+	LogLevel() slog.Level
+	SetLogLevel(slog.Level)
 }
 
 type orderService struct {
 	orderv20.UnimplementedOrderServiceServer
-	order OrderService
+	order    OrderService
+	reports  ReportService
+	importer Importer
+	logLevel LogLevel
 }
 
-func Register(gRPCServer *grpc.Server, order OrderService) {
-	orderv20.RegisterOrderServiceServer(gRPCServer, &orderService{order: order})
+func Register(gRPCServer *grpc.Server, order OrderService, reports ReportService, importer Importer, logLevel LogLevel) {
+	gRPCServer.RegisterService(&serviceDesc, &orderService{
+		order:    order,
+		reports:  reports,
+		importer: importer,
+		logLevel: logLevel,
+	})
 }
 
 func (os *orderService) CreateOrder(ctx context.Context, req *orderv20.CreateOrderRequest) (*orderv20.CreateOrderResponse, error) {
-	var ord dto.OrderDTO
-	err := copier.Copy(&ord, req.Order)
-	if err != nil {
-		log.Fatalf("failed to copy %v", err)
+	if err := validateCreateOrderRequest(req); err != nil {
 		return nil, err
 	}
 
-	orderDTO, err := os.order.CreateOrder(ctx, &ord)
+	created, err := os.order.CreateOrder(ctx, orderFromProto(req.GetOrder()))
 	if err != nil {
+		if errors.Is(err, order.ErrItemNotFound) {
+			return nil, status.Error(codes.NotFound, "item not found")
+		}
+		if errors.Is(err, order.ErrQuotaExceeded) {
+			return nil, status.Error(codes.ResourceExhausted, "daily order quota exceeded")
+		}
 		return nil, status.Error(codes.Internal, "error with create ord")
 	}
 
-	var response orderv20.Order
-	err = copier.Copy(&response, orderDTO)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "error with copying to dto")
-	}
-
-	return &orderv20.CreateOrderResponse{Order: &response}, nil
+	return &orderv20.CreateOrderResponse{Order: orderToProto(created)}, nil
 }
 
 func (os *orderService) ListOrders(ctx context.Context, req *orderv20.ListOrdersRequest) (*orderv20.ListOrdersResponse, error) {
-	var responseOrders []*orderv20.Order
-
-	orders, err := os.order.ListOrders(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	filter, err := listFilterFromMetadata(md)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, item := range orders {
-		var ord orderv20.Order
-		err := copier.Copy(&ord, &item)
-		if err != nil {
-			log.Fatalf("failed to copy %v", err)
-			return nil, err
-		}
-
-		responseOrders = append(responseOrders, &ord)
+	orders, err := os.order.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return &orderv20.ListOrdersResponse{Orders: responseOrders}, nil
+	return &orderv20.ListOrdersResponse{Orders: ordersToProto(orders)}, nil
 }
 
 func (os *orderService) GetOrder(ctx context.Context, req *orderv20.GetOrderRequest) (*orderv20.GetOrderResponse, error) {
-	id, err := strconv.Atoi(req.Id)
-	if err != nil {
+	if err := validateGetOrderRequest(req); err != nil {
 		return nil, err
 	}
 
+	id, _ := strconv.Atoi(req.Id)
+
 	order, err := os.order.GetOrder(ctx, id)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "order not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to get order")
 	}
 
-	orderResponse := &orderv20.Order{
-		Id:     order.ID,
-		ItemId: order.ItemId,
-		UserId: order.UserId,
-	}
-	return &orderv20.GetOrderResponse{Order: orderResponse}, nil
+	return &orderv20.GetOrderResponse{Order: orderToProto(order)}, nil
 }
 
 func (os *orderService) GetOrderByUserId(ctx context.Context, req *orderv20.GetOrdersByUserId) (*orderv20.ListOrdersResponse, error) {
-	userId := req.GetUserId()
-	if userId == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	if err := validateGetOrdersByUserId(req); err != nil {
+		return nil, err
 	}
+
+	userId := req.GetUserId()
 	ordersByUserId, err := os.order.GetOrdersByUserId(ctx, int(userId))
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get orders of user")
 	}
 
-	var ordersResponse []*orderv20.Order
-	for _, order := range ordersByUserId {
-		var o orderv20.Order
-		err := copier.Copy(&o, order)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to copy to response order")
+	return &orderv20.ListOrdersResponse{Orders: ordersToProto(ordersByUserId)}, nil
+}
+
+func (os *orderService) CancelOrder(ctx context.Context, req *orderv20.DeleteOrderRequest) (*orderv20.DeleteOrderResponse, error) {
+	if err := validateCancelOrderRequest(req); err != nil {
+		return nil, err
+	}
+
+	err := os.order.CancelOrder(ctx, int(req.GetId()))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, status.Error(codes.NotFound, "order not found")
+		case errors.Is(err, data.ErrOrderCancelled):
+			return nil, status.Error(codes.FailedPrecondition, "order is already cancelled")
+		default:
+			return nil, status.Error(codes.Internal, "failed to cancel order")
 		}
+	}
 
-		ordersResponse = append(ordersResponse, &o)
+	return &orderv20.DeleteOrderResponse{IsDeleted: true}, nil
+}
+
+func (os *orderService) SetLogLevel(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	if err := validateSetLogLevelRequest(req); err != nil {
+		return nil, err
+	}
+
+	if req.GetValue() != "" {
+		var level slog.Level
+		_ = level.UnmarshalText([]byte(req.GetValue()))
+		os.logLevel.SetLogLevel(level)
+	}
+
+	return wrapperspb.String(strings.ToLower(os.logLevel.LogLevel().String())), nil
+}
+
+func (os *orderService) SearchOrders(ctx context.Context, req *structpb.Struct) (*orderv20.ListOrdersResponse, error) {
+	filter, err := searchFilterFromStruct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, total, err := os.order.SearchOrders(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to search orders")
 	}
 
-	var items []*cataloguev20.Item
-	items = append(items, &cataloguev20.Item{Id: 1})
-	items = append(items, &cataloguev20.Item{Id: 2})
-	items = append(items, &cataloguev20.Item{Id: 3})
-	items = append(items, &cataloguev20.Item{Id: 4})
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataTotalCount, strconv.Itoa(total)))
 
-	return &orderv20.ListOrdersResponse{Orders: ordersResponse}, nil
+	return &orderv20.ListOrdersResponse{Orders: ordersToProto(orders)}, nil
 }
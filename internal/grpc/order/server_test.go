@@ -0,0 +1,406 @@
+package orderGrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeOrders is an OrderService answering with its fields and recording
+// what it was asked.
+type fakeOrders struct {
+	created  *domain.Order
+	orders   []*domain.Order
+	total    int
+	err      error
+	filter   models.OrderFilter
+	search   models.SearchFilter
+	id       int
+	received *domain.Order
+}
+
+func (f *fakeOrders) CreateOrder(_ context.Context, o *domain.Order) (*domain.Order, error) {
+	f.received = o
+	return f.created, f.err
+}
+
+func (f *fakeOrders) ListOrders(_ context.Context, filter models.OrderFilter) ([]*domain.Order, error) {
+	f.filter = filter
+	return f.orders, f.err
+}
+
+func (f *fakeOrders) GetOrder(_ context.Context, id int) (*domain.Order, error) {
+	f.id = id
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.orders[0], nil
+}
+
+func (f *fakeOrders) GetOrdersByUserId(_ context.Context, userId int) ([]*domain.Order, error) {
+	f.id = userId
+	return f.orders, f.err
+}
+
+func (f *fakeOrders) CancelOrder(_ context.Context, id int) error {
+	f.id = id
+	return f.err
+}
+
+func (f *fakeOrders) SearchOrders(_ context.Context, filter models.SearchFilter) ([]*domain.Order, int, error) {
+	f.search = filter
+	return f.orders, f.total, f.err
+}
+
+// bowlOrder is a stored order of the bowl, item 3.
+func bowlOrder(id, userId int32) *domain.Order {
+	return &domain.Order{
+		ID:     id,
+		UserId: userId,
+		Status: domain.StatusCreated,
+		Lines: []domain.Line{{
+			Item:     domain.Item{ID: 3, Name: "bowl", Price: 450, Description: "steel", Quantity: 2, ImageURL: "https://img/bowl.png"},
+			Quantity: 1,
+		}},
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+var bowlProto = &cataloguev20.Item{Id: 3, Name: "bowl", Price: 450, Description: "steel", Quantity: 2, ImageUrl: "https://img/bowl.png"}
+
+type fakeLogLevel struct {
+	level slog.Level
+}
+
+func (f *fakeLogLevel) LogLevel() slog.Level     { return f.level }
+func (f *fakeLogLevel) SetLogLevel(l slog.Level) { f.level = l }
+
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("got %v (%v), want %v", got, err, want)
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	orders := &fakeOrders{created: bowlOrder(9, 2)}
+	srv := &orderService{order: orders}
+
+	resp, err := srv.CreateOrder(context.Background(), &orderv20.CreateOrderRequest{Order: &orderv20.Order{
+		UserId: 2,
+		ItemId: 3,
+		Item:   &cataloguev20.Item{Id: 3, Name: "bowl", Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantReceived := domain.NewOrder(2, domain.Item{ID: 3, Name: "bowl", Quantity: 2})
+	if !reflect.DeepEqual(orders.received, wantReceived) {
+		t.Errorf("service got %+v, want %+v", orders.received, wantReceived)
+	}
+
+	want := &orderv20.Order{Id: 9, UserId: 2, ItemId: 3, Item: bowlProto}
+	if !proto.Equal(resp.GetOrder(), want) {
+		t.Errorf("got %v, want %v", resp.GetOrder(), want)
+	}
+}
+
+func TestCreateOrder_Errors(t *testing.T) {
+	valid := &orderv20.CreateOrderRequest{Order: &orderv20.Order{UserId: 2, ItemId: 3}}
+
+	tests := []struct {
+		name string
+		req  *orderv20.CreateOrderRequest
+		err  error
+		want codes.Code
+	}{
+		{"no order", &orderv20.CreateOrderRequest{}, nil, codes.InvalidArgument},
+		{"id set", &orderv20.CreateOrderRequest{Order: &orderv20.Order{Id: 1, UserId: 2, ItemId: 3}}, nil, codes.InvalidArgument},
+		{"no item", &orderv20.CreateOrderRequest{Order: &orderv20.Order{UserId: 2}}, nil, codes.InvalidArgument},
+		{"item mismatch", &orderv20.CreateOrderRequest{Order: &orderv20.Order{UserId: 2, ItemId: 3, Item: &cataloguev20.Item{Id: 4}}}, nil, codes.InvalidArgument},
+		{"quantity too large", &orderv20.CreateOrderRequest{Order: &orderv20.Order{UserId: 2, ItemId: 3, Item: &cataloguev20.Item{Quantity: maxItemQuantity + 1}}}, nil, codes.InvalidArgument},
+		{"item not found", valid, order.ErrItemNotFound, codes.NotFound},
+		{"quota exceeded", valid, order.ErrQuotaExceeded, codes.ResourceExhausted},
+		{"service failure", valid, errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &orderService{order: &fakeOrders{err: tt.err}}
+			_, err := srv.CreateOrder(context.Background(), tt.req)
+			assertCode(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidationDetails(t *testing.T) {
+	srv := &orderService{order: &fakeOrders{}}
+
+	_, err := srv.CreateOrder(context.Background(), &orderv20.CreateOrderRequest{Order: &orderv20.Order{Id: 1}})
+
+	st := status.Convert(err)
+	if len(st.Details()) != 1 {
+		t.Fatalf("got details %v", st.Details())
+	}
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("got detail %T", st.Details()[0])
+	}
+
+	var fields []string
+	for _, v := range br.GetFieldViolations() {
+		fields = append(fields, v.GetField())
+	}
+	want := []string{"order.id", "order.item_id", "order.user_id"}
+	if len(fields) != len(want) {
+		t.Fatalf("got violations %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("got violations %v, want %v", fields, want)
+		}
+	}
+	if st.Message() != "invalid request: order.id must not be set" {
+		t.Errorf("got message %q", st.Message())
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	orders := &fakeOrders{orders: []*domain.Order{bowlOrder(1, 2), bowlOrder(4, 2)}}
+	srv := &orderService{order: orders}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		MetadataListUserId, "2",
+		MetadataListStatus, models.StatusCreated,
+		MetadataListLimit, "10",
+		MetadataListOffset, "20",
+	))
+	resp, err := srv.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFilter := models.OrderFilter{UserId: 2, Status: models.StatusCreated, Limit: 10, Offset: 20}
+	if orders.filter != wantFilter {
+		t.Errorf("got filter %+v, want %+v", orders.filter, wantFilter)
+	}
+
+	want := []*orderv20.Order{{Id: 1, UserId: 2, ItemId: 3, Item: bowlProto}, {Id: 4, UserId: 2, ItemId: 3, Item: bowlProto}}
+	if len(resp.GetOrders()) != len(want) {
+		t.Fatalf("got %v", resp.GetOrders())
+	}
+	for i := range want {
+		if !proto.Equal(resp.GetOrders()[i], want[i]) {
+			t.Errorf("order %d: got %v, want %v", i, resp.GetOrders()[i], want[i])
+		}
+	}
+}
+
+func TestListOrders_InvalidFilter(t *testing.T) {
+	tests := map[string][]string{
+		"user id":  {MetadataListUserId, "abc"},
+		"negative": {MetadataListOffset, "-1"},
+		"limit":    {MetadataListLimit, "1001"},
+		"status":   {MetadataListStatus, "shipped"},
+	}
+
+	for name, kv := range tests {
+		t.Run(name, func(t *testing.T) {
+			orders := &fakeOrders{}
+			srv := &orderService{order: orders}
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+
+			_, err := srv.ListOrders(ctx, &orderv20.ListOrdersRequest{})
+			assertCode(t, err, codes.InvalidArgument)
+		})
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		err  error
+		want codes.Code
+	}{
+		{"found", "5", nil, codes.OK},
+		{"not numeric", "five", nil, codes.InvalidArgument},
+		{"empty", "", nil, codes.InvalidArgument},
+		{"not found", "5", data.ErrRecordNotFound, codes.NotFound},
+		{"storage failure", "5", errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := &fakeOrders{orders: []*domain.Order{bowlOrder(5, 2)}, err: tt.err}
+			srv := &orderService{order: orders}
+
+			resp, err := srv.GetOrder(context.Background(), &orderv20.GetOrderRequest{Id: tt.id})
+			assertCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+			if orders.id != 5 {
+				t.Errorf("asked for order %d", orders.id)
+			}
+			want := &orderv20.Order{Id: 5, UserId: 2, ItemId: 3, Item: bowlProto}
+			if !proto.Equal(resp.GetOrder(), want) {
+				t.Errorf("got %v, want %v", resp.GetOrder(), want)
+			}
+		})
+	}
+}
+
+func TestGetOrderByUserId(t *testing.T) {
+	orders := &fakeOrders{orders: []*domain.Order{bowlOrder(1, 2)}}
+	srv := &orderService{order: orders}
+
+	resp, err := srv.GetOrderByUserId(context.Background(), &orderv20.GetOrdersByUserId{UserId: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orders.id != 2 {
+		t.Errorf("asked for user %d", orders.id)
+	}
+	want := &orderv20.Order{Id: 1, UserId: 2, ItemId: 3, Item: bowlProto}
+	if len(resp.GetOrders()) != 1 || !proto.Equal(resp.GetOrders()[0], want) {
+		t.Errorf("got %v, want [%v]", resp.GetOrders(), want)
+	}
+
+	_, err = srv.GetOrderByUserId(context.Background(), &orderv20.GetOrdersByUserId{})
+	assertCode(t, err, codes.InvalidArgument)
+
+	srv.order = &fakeOrders{err: errors.New("boom")}
+	_, err = srv.GetOrderByUserId(context.Background(), &orderv20.GetOrdersByUserId{UserId: 2})
+	assertCode(t, err, codes.Internal)
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		id   int32
+		err  error
+		want codes.Code
+	}{
+		{"cancelled", 5, nil, codes.OK},
+		{"no id", 0, nil, codes.InvalidArgument},
+		{"not found", 5, data.ErrRecordNotFound, codes.NotFound},
+		{"already cancelled", 5, data.ErrOrderCancelled, codes.FailedPrecondition},
+		{"storage failure", 5, errors.New("boom"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &orderService{order: &fakeOrders{err: tt.err}}
+
+			resp, err := srv.CancelOrder(context.Background(), &orderv20.DeleteOrderRequest{Id: tt.id})
+			assertCode(t, err, tt.want)
+			if tt.want == codes.OK && !resp.GetIsDeleted() {
+				t.Error("order not reported deleted")
+			}
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	level := &fakeLogLevel{level: slog.LevelInfo}
+	srv := &orderService{logLevel: level}
+
+	resp, err := srv.SetLogLevel(context.Background(), wrapperspb.String(""))
+	if err != nil || resp.GetValue() != "info" {
+		t.Errorf("got %v, %v", resp, err)
+	}
+
+	resp, err = srv.SetLogLevel(context.Background(), wrapperspb.String("debug"))
+	if err != nil || resp.GetValue() != "debug" || level.level != slog.LevelDebug {
+		t.Errorf("got %v, %v", resp, err)
+	}
+
+	_, err = srv.SetLogLevel(context.Background(), wrapperspb.String("verbose"))
+	assertCode(t, err, codes.InvalidArgument)
+	if level.level != slog.LevelDebug {
+		t.Errorf("level changed to %v", level.level)
+	}
+}
+
+func TestSearchOrders(t *testing.T) {
+	orders := &fakeOrders{orders: []*domain.Order{bowlOrder(1, 2)}, total: 12}
+	srv := &orderService{order: orders}
+
+	req, _ := structpb.NewStruct(map[string]interface{}{
+		"query":   "  bowl ",
+		"user_id": 2,
+		"from":    "2024-01-01T00:00:00Z",
+		"to":      "2024-02-01T00:00:00Z",
+		"limit":   5,
+		"offset":  10,
+	})
+	resp, err := srv.SearchOrders(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFilter := models.SearchFilter{
+		Query:  "bowl",
+		UserId: 2,
+		From:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Limit:  5,
+		Offset: 10,
+	}
+	if orders.search != wantFilter {
+		t.Errorf("got filter %+v, want %+v", orders.search, wantFilter)
+	}
+	want := &orderv20.Order{Id: 1, UserId: 2, ItemId: 3, Item: bowlProto}
+	if len(resp.GetOrders()) != 1 || !proto.Equal(resp.GetOrders()[0], want) {
+		t.Errorf("got %v, want [%v]", resp.GetOrders(), want)
+	}
+}
+
+func TestSearchOrders_Invalid(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no query":      {},
+		"blank query":   {"query": "   "},
+		"unknown field": {"query": "bowl", "sort": "id"},
+		"wrong type":    {"query": 5},
+		"fraction":      {"query": "bowl", "limit": 1.5},
+		"limit":         {"query": "bowl", "limit": maxSearchLimit + 1},
+		"bad time":      {"query": "bowl", "from": "yesterday"},
+		"empty range":   {"query": "bowl", "from": "2024-02-01T00:00:00Z", "to": "2024-01-01T00:00:00Z"},
+	}
+
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := &orderService{order: &fakeOrders{}}
+			req, err := structpb.NewStruct(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = srv.SearchOrders(context.Background(), req)
+			assertCode(t, err, codes.InvalidArgument)
+		})
+	}
+
+	srv := &orderService{order: &fakeOrders{err: errors.New("boom")}}
+	req, _ := structpb.NewStruct(map[string]interface{}{"query": "bowl"})
+	_, err := srv.SearchOrders(context.Background(), req)
+	assertCode(t, err, codes.Internal)
+}
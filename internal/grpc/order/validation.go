@@ -0,0 +1,388 @@
+package orderGrpc
+
+import (
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	orderv20 "github.com/bxiit/protos/gen/go/order"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxItemQuantity    = 1000
+	maxNameLength      = 255
+	maxDescriptionSize = 2048
+	maxImageURLLength  = 2048
+	maxListLimit       = 1000
+	maxQueryLength     = 200
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	defaultReportLimit = 10
+	maxReportLimit     = 1000
+)
+
+// rule is a single declarative constraint on a request field. check returns
+// an empty string when the field is valid and a human-readable reason otherwise.
+type rule struct {
+	field string
+	check func() string
+}
+
+func required(field string, present bool) rule {
+	return rule{field, func() string {
+		if !present {
+			return "is required"
+		}
+		return ""
+	}}
+}
+
+func unset(field string, v int64) rule {
+	return rule{field, func() string {
+		if v != 0 {
+			return "must not be set"
+		}
+		return ""
+	}}
+}
+
+func positive(field string, v int64) rule {
+	return rule{field, func() string {
+		if v <= 0 {
+			return "must be a positive number"
+		}
+		return ""
+	}}
+}
+
+func between(field string, v, min, max int64) rule {
+	return rule{field, func() string {
+		if v < min || v > max {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}}
+}
+
+func maxLength(field, v string, max int) rule {
+	return rule{field, func() string {
+		if len([]rune(v)) > max {
+			return fmt.Sprintf("must be at most %d characters long", max)
+		}
+		return ""
+	}}
+}
+
+func oneOf(field, v string, allowed ...string) rule {
+	return rule{field, func() string {
+		for _, a := range allowed {
+			if v == a {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %q", allowed)
+	}}
+}
+
+func integer(field, v string, dst *int) rule {
+	return rule{field, func() string {
+		if v == "" {
+			return ""
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "must be an integer"
+		}
+		*dst = n
+		return ""
+	}}
+}
+
+func numericID(field, v string) rule {
+	return rule{field, func() string {
+		if v == "" {
+			return "is required"
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return "must be a positive integer"
+		}
+		return ""
+	}}
+}
+
+func timestamp(field, v string, dst *time.Time) rule {
+	return rule{field, func() string {
+		if v == "" {
+			return ""
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "must be an RFC 3339 timestamp"
+		}
+		*dst = t
+		return ""
+	}}
+}
+
+// stringField and numberField read a structpb field into dst, leaving dst
+// alone when the field is absent.
+func stringField(field string, v *structpb.Value, dst *string) rule {
+	return rule{field, func() string {
+		if v == nil {
+			return ""
+		}
+		sv, ok := v.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return "must be a string"
+		}
+		*dst = sv.StringValue
+		return ""
+	}}
+}
+
+func numberField(field string, v *structpb.Value, dst *int) rule {
+	return rule{field, func() string {
+		if v == nil {
+			return ""
+		}
+		nv, ok := v.GetKind().(*structpb.Value_NumberValue)
+		if !ok || nv.NumberValue != math.Trunc(nv.NumberValue) || math.Abs(nv.NumberValue) > 1<<31-1 {
+			return "must be an integer"
+		}
+		*dst = int(nv.NumberValue)
+		return ""
+	}}
+}
+
+func knownFields(s *structpb.Struct, allowed ...string) []rule {
+	var rules []rule
+	for name := range s.GetFields() {
+		if !slices.Contains(allowed, name) {
+			rules = append(rules, rule{name, func() string { return "is not a known field" }})
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].field < rules[j].field })
+	return rules
+}
+
+// validate runs every rule and returns an InvalidArgument status carrying a
+// BadRequest detail with one violation per failed field, or nil.
+func validate(rules ...rule) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, r := range rules {
+		if reason := r.check(); reason != "" {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       r.field,
+				Description: r.field + " " + reason,
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+violations[0].Description)
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+func validateCreateOrderRequest(req *orderv20.CreateOrderRequest) error {
+	order := req.GetOrder()
+	if order == nil {
+		return validate(required("order", false))
+	}
+
+	rules := []rule{
+		unset("order.id", int64(order.GetId())),
+		positive("order.item_id", int64(order.GetItemId())),
+		positive("order.user_id", int64(order.GetUserId())),
+	}
+
+	if item := order.GetItem(); item != nil {
+		rules = append(rules,
+			between("order.item.quantity", int64(item.GetQuantity()), 0, maxItemQuantity),
+			maxLength("order.item.name", item.GetName(), maxNameLength),
+			maxLength("order.item.description", item.GetDescription(), maxDescriptionSize),
+			maxLength("order.item.image_url", item.GetImageUrl(), maxImageURLLength),
+		)
+		if item.GetId() != 0 && item.GetId() != order.GetItemId() {
+			rules = append(rules, rule{"order.item.id", func() string {
+				return "must match order.item_id"
+			}})
+		}
+	}
+
+	return validate(rules...)
+}
+
+func validateGetOrderRequest(req *orderv20.GetOrderRequest) error {
+	return validate(
+		numericID("id", req.GetId()),
+	)
+}
+
+func validateGetOrdersByUserId(req *orderv20.GetOrdersByUserId) error {
+	return validate(
+		positive("user_id", int64(req.GetUserId())),
+	)
+}
+
+func validateCancelOrderRequest(req *orderv20.DeleteOrderRequest) error {
+	return validate(
+		positive("id", int64(req.GetId())),
+	)
+}
+
+func validateSetLogLevelRequest(req *wrapperspb.StringValue) error {
+	if req.GetValue() == "" {
+		return nil
+	}
+	return validate(oneOf("value", req.GetValue(), "debug", "info", "warn", "error"))
+}
+
+// listFilterFromMetadata reads the optional ListOrders filters from the
+// incoming metadata and validates them.
+func listFilterFromMetadata(md metadata.MD) (models.OrderFilter, error) {
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	var userId, limit, offset int
+	filter := models.OrderFilter{Status: first(MetadataListStatus)}
+
+	err := validate(
+		integer("user_id", first(MetadataListUserId), &userId),
+		integer("limit", first(MetadataListLimit), &limit),
+		integer("offset", first(MetadataListOffset), &offset),
+	)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.UserId = int32(userId)
+	filter.Limit = limit
+	filter.Offset = offset
+
+	err = validate(
+		between("user_id", int64(userId), 0, 1<<31-1),
+		between("limit", int64(limit), 0, maxListLimit),
+		between("offset", int64(offset), 0, 1<<31-1),
+		oneOf("status", filter.Status, "", models.StatusCreated, models.StatusCancelled),
+	)
+
+	return filter, err
+}
+
+// searchFilterFromStruct reads and validates the SearchOrders request: query
+// is required, user_id, from, to, limit and offset are optional.
+func searchFilterFromStruct(req *structpb.Struct) (models.SearchFilter, error) {
+	fields := req.GetFields()
+	filter := models.SearchFilter{Limit: defaultSearchLimit}
+
+	var userId int
+	var from, to string
+	rules := append(knownFields(req, "query", "user_id", "from", "to", "limit", "offset"),
+		stringField("query", fields["query"], &filter.Query),
+		numberField("user_id", fields["user_id"], &userId),
+		stringField("from", fields["from"], &from),
+		stringField("to", fields["to"], &to),
+		numberField("limit", fields["limit"], &filter.Limit),
+		numberField("offset", fields["offset"], &filter.Offset),
+	)
+	if err := validate(rules...); err != nil {
+		return filter, err
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.UserId = int32(userId)
+
+	err := validate(
+		required("query", filter.Query != ""),
+		maxLength("query", filter.Query, maxQueryLength),
+		between("user_id", int64(userId), 0, 1<<31-1),
+		timestamp("from", from, &filter.From),
+		timestamp("to", to, &filter.To),
+		between("limit", int64(filter.Limit), 1, maxSearchLimit),
+		between("offset", int64(filter.Offset), 0, 1<<31-1),
+	)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, validate(rule{"to", func() string {
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+			return "must be after from"
+		}
+		return ""
+	}})
+}
+
+// reportFilterFromStruct reads and validates a report request. Every report
+// takes the optional "from", "to" (RFC 3339) and "timezone" (IANA name,
+// UTC by default); extra names the report-specific fields allowed on top,
+// out of "granularity", "limit" and "by".
+func reportFilterFromStruct(req *structpb.Struct, extra ...string) (models.ReportFilter, error) {
+	fields := req.GetFields()
+	filter := models.ReportFilter{
+		Timezone:    "UTC",
+		Granularity: models.GranularityDay,
+		Limit:       defaultReportLimit,
+		By:          models.ByQuantity,
+	}
+
+	var from, to string
+	rules := append(knownFields(req, append([]string{"from", "to", "timezone"}, extra...)...),
+		stringField("from", fields["from"], &from),
+		stringField("to", fields["to"], &to),
+		stringField("timezone", fields["timezone"], &filter.Timezone),
+		stringField("granularity", fields["granularity"], &filter.Granularity),
+		numberField("limit", fields["limit"], &filter.Limit),
+		stringField("by", fields["by"], &filter.By),
+	)
+	if err := validate(rules...); err != nil {
+		return filter, err
+	}
+
+	err := validate(
+		timestamp("from", from, &filter.From),
+		timestamp("to", to, &filter.To),
+		rule{"timezone", func() string {
+			if _, err := time.LoadLocation(filter.Timezone); err != nil || filter.Timezone == "" || filter.Timezone == "Local" {
+				return "must be an IANA time zone name"
+			}
+			return ""
+		}},
+		oneOf("granularity", filter.Granularity, models.GranularityDay, models.GranularityWeek, models.GranularityMonth),
+		between("limit", int64(filter.Limit), 1, maxReportLimit),
+		oneOf("by", filter.By, models.ByQuantity, models.ByRevenue),
+	)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, validate(rule{"to", func() string {
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+			return "must be after from"
+		}
+		return ""
+	}})
+}
@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// cache is a small TTL map. Expired entries are dropped lazily on lookup and
+// swept whenever the map has doubled since the last sweep.
+type cache struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	sweepSize int
+}
+
+const minSweepSize = 1024
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl:       ttl,
+		entries:   make(map[string]cacheEntry),
+		sweepSize: minSweepSize,
+	}
+}
+
+func (c *cache) get(key string) (interface{}, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+func (c *cache) set(key string, value interface{}) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+
+	if len(c.entries) >= c.sweepSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepSize = max(minSweepSize, 2*len(c.entries))
+	}
+}
@@ -0,0 +1,213 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Provider answers identity questions about the caller of an RPC.
+type Provider interface {
+	UserInfo(ctx context.Context, token string) (*ssov1.User, error)
+	// CachedUserInfo answers UserInfo only from what is already known,
+	// without calling out; ok is false otherwise.
+	CachedUserInfo(token string) (user *ssov1.User, ok bool)
+	IsAdmin(ctx context.Context, userId int64) (bool, error)
+	IsAuthenticated(ctx context.Context, token string) (bool, error)
+}
+
+type Options struct {
+	Timeout          time.Duration
+	Retries          int
+	CacheTTL         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// SSO is a Provider backed by the SSO service. Answers are cached for
+// Options.CacheTTL, transient failures are retried and, after
+// Options.BreakerThreshold consecutive failures, calls fail fast with
+// codes.Unavailable until Options.BreakerCooldown has passed.
+type SSO struct {
+	log            *slog.Logger
+	authClient     ssov1.AuthClient
+	userInfoClient ssov1.UserInfoClient
+	opts           Options
+	cache          *cache
+	breaker        *breaker
+}
+
+const retryBackoff = 50 * time.Millisecond
+
+func New(
+	log *slog.Logger,
+	authClient ssov1.AuthClient,
+	userInfoClient ssov1.UserInfoClient,
+	opts Options,
+) *SSO {
+	return &SSO{
+		log:            log,
+		authClient:     authClient,
+		userInfoClient: userInfoClient,
+		opts:           opts,
+		cache:          newCache(opts.CacheTTL),
+		breaker:        newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+}
+
+func (s *SSO) UserInfo(ctx context.Context, token string) (*ssov1.User, error) {
+	key := "user:" + hashToken(token)
+	if v, ok := s.cache.get(key); ok {
+		return v.(*ssov1.User), nil
+	}
+
+	var resp *ssov1.GetUserInfoResponse
+	err := s.call(ctx, "GetUserInfo", func(ctx context.Context) (err error) {
+		resp, err = s.userInfoClient.GetUserInfo(ctx, &ssov1.GetUserInfoRequest{Token: token})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetUser() == nil {
+		return nil, status.Error(codes.Unauthenticated, "unknown token")
+	}
+
+	s.cache.set(key, resp.GetUser())
+
+	return resp.GetUser(), nil
+}
+
+func (s *SSO) CachedUserInfo(token string) (*ssov1.User, bool) {
+	v, ok := s.cache.get("user:" + hashToken(token))
+	if !ok {
+		return nil, false
+	}
+	return v.(*ssov1.User), true
+}
+
+func (s *SSO) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+	key := "admin:" + strconv.FormatInt(userId, 10)
+	if v, ok := s.cache.get(key); ok {
+		return v.(bool), nil
+	}
+
+	var resp *ssov1.IsAdminResponse
+	err := s.call(ctx, "IsAdmin", func(ctx context.Context) (err error) {
+		resp, err = s.authClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: userId})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	s.cache.set(key, resp.GetIsAdmin())
+
+	return resp.GetIsAdmin(), nil
+}
+
+func (s *SSO) IsAuthenticated(ctx context.Context, token string) (bool, error) {
+	key := "auth:" + hashToken(token)
+	if v, ok := s.cache.get(key); ok {
+		return v.(bool), nil
+	}
+
+	var resp *ssov1.IsAuthenticatedResponse
+	err := s.call(ctx, "IsAuthenticated", func(ctx context.Context) (err error) {
+		resp, err = s.authClient.IsAuthenticated(ctx, &ssov1.IsAuthenticatedRequest{Token: token})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	s.cache.set(key, resp.GetIsAuthenticated())
+
+	return resp.GetIsAuthenticated(), nil
+}
+
+// call runs fn with a per-attempt timeout, retrying transient failures and
+// feeding the outcome into the circuit breaker.
+func (s *SSO) call(ctx context.Context, method string, fn func(context.Context) error) error {
+	const op = "identity.call"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("method", method),
+	)
+
+	if !s.breaker.allow() {
+		return status.Error(codes.Unavailable, "sso service is unavailable")
+	}
+
+	var err error
+	for attempt := 0; attempt <= s.opts.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryBackoff << (attempt - 1)):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		err = s.attempt(ctx, fn)
+		if err == nil {
+			s.breaker.success()
+			return nil
+		}
+
+		if !transient(err) || ctx.Err() != nil {
+			break
+		}
+
+		log.Debug("retrying sso call", slog.Int("attempt", attempt+1), sl.Err(err))
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// The caller ran out of time, which says nothing about SSO itself.
+		s.breaker.release()
+		err = status.FromContextError(ctx.Err()).Err()
+	case transient(err):
+		s.breaker.failure()
+	default:
+		s.breaker.success()
+	}
+
+	return fmt.Errorf("%s: %s: %w", op, method, err)
+}
+
+func (s *SSO) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if s.opts.Timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	return fn(ctx)
+}
+
+func transient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,261 @@
+package identity
+
+import (
+	"context"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// client answers IsAdmin and GetUserInfo with the errors in errs, one per
+// call, then with success. Other Auth methods are not used by SSO.
+type client struct {
+	ssov1.AuthClient
+
+	errs  []error
+	calls int
+	// block, if set, makes a call wait for its context instead of answering.
+	block bool
+}
+
+func (c *client) next(ctx context.Context) error {
+	c.calls++
+	if c.block {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (c *client) IsAdmin(ctx context.Context, in *ssov1.IsAdminRequest, _ ...grpc.CallOption) (*ssov1.IsAdminResponse, error) {
+	if err := c.next(ctx); err != nil {
+		return nil, err
+	}
+	return &ssov1.IsAdminResponse{IsAdmin: in.GetUserId() == 1}, nil
+}
+
+func (c *client) GetUserInfo(ctx context.Context, in *ssov1.GetUserInfoRequest, _ ...grpc.CallOption) (*ssov1.GetUserInfoResponse, error) {
+	if err := c.next(ctx); err != nil {
+		return nil, err
+	}
+	if in.GetToken() != "good" {
+		return &ssov1.GetUserInfoResponse{}, nil
+	}
+	return &ssov1.GetUserInfoResponse{User: &ssov1.User{Id: 1}}, nil
+}
+
+var unavailable = status.Error(codes.Unavailable, "sso is down")
+
+func newTestSSO(c *client, opts Options) *SSO {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), c, c, opts)
+}
+
+func TestSSO_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		retries   int
+		wantCode  codes.Code
+		wantCalls int
+	}{
+		{"no error", nil, 2, codes.OK, 1},
+		{"recovers", []error{unavailable, unavailable}, 2, codes.OK, 3},
+		{"retries exhausted", []error{unavailable, unavailable}, 1, codes.Unavailable, 2},
+		{"deadline retried", []error{status.Error(codes.DeadlineExceeded, "slow")}, 1, codes.OK, 2},
+		{"not transient", []error{status.Error(codes.PermissionDenied, "no")}, 2, codes.PermissionDenied, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{errs: tt.errs}
+			s := newTestSSO(c, Options{Retries: tt.retries})
+
+			admin, err := s.IsAdmin(context.Background(), 1)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("got %v (%v), want %v", got, err, tt.wantCode)
+			}
+			if err == nil && !admin {
+				t.Error("got not admin")
+			}
+			if c.calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", c.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSSO_AttemptTimeout(t *testing.T) {
+	c := &client{block: true}
+	s := newTestSSO(c, Options{Timeout: 10 * time.Millisecond, Retries: 1})
+
+	_, err := s.IsAdmin(context.Background(), 1)
+	if got := status.Code(err); got != codes.DeadlineExceeded {
+		t.Fatalf("got %v", err)
+	}
+	if c.calls != 2 {
+		t.Errorf("got %d calls, want 2", c.calls)
+	}
+}
+
+func TestSSO_Breaker(t *testing.T) {
+	c := &client{errs: []error{unavailable, unavailable, unavailable}}
+	s := newTestSSO(c, Options{BreakerThreshold: 2, BreakerCooldown: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.IsAdmin(ctx, 1); status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: got %v", i, err)
+		}
+	}
+
+	// Open: calls fail fast without reaching SSO.
+	_, err := s.IsAdmin(ctx, 1)
+	if status.Code(err) != codes.Unavailable || c.calls != 2 {
+		t.Fatalf("got %v after %d calls", err, c.calls)
+	}
+
+	// After the cooldown a failed probe reopens it.
+	s.breaker.openUntil = time.Now()
+	if _, err := s.IsAdmin(ctx, 1); status.Code(err) != codes.Unavailable || c.calls != 3 {
+		t.Fatalf("probe: got %v after %d calls", err, c.calls)
+	}
+	if _, err := s.IsAdmin(ctx, 1); c.calls != 3 {
+		t.Fatalf("reopened: got %v after %d calls", err, c.calls)
+	}
+
+	// A successful probe closes it.
+	s.breaker.openUntil = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := s.IsAdmin(ctx, 2); err != nil {
+			t.Fatalf("closed, call %d: got %v", i, err)
+		}
+	}
+}
+
+func TestSSO_Breaker_NotTransient(t *testing.T) {
+	c := &client{errs: []error{
+		unavailable,
+		status.Error(codes.InvalidArgument, "bad id"),
+		unavailable,
+	}}
+	s := newTestSSO(c, Options{BreakerThreshold: 2, BreakerCooldown: time.Hour})
+
+	// An answer, even a refusal, proves SSO is up and resets the count.
+	for i := 0; i < 3; i++ {
+		s.IsAdmin(context.Background(), 1)
+	}
+	if _, err := s.IsAdmin(context.Background(), 1); err != nil || c.calls != 4 {
+		t.Fatalf("got %v after %d calls", err, c.calls)
+	}
+}
+
+func TestSSO_Cancel(t *testing.T) {
+	c := &client{errs: []error{unavailable}}
+	s := newTestSSO(c, Options{Retries: 3, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+	// Cancelled while backing off before the second attempt.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(retryBackoff/5, cancel)
+
+	_, err := s.IsAdmin(ctx, 1)
+	if got := status.Code(err); got != codes.Canceled {
+		t.Fatalf("got %v", err)
+	}
+	if c.calls != 1 {
+		t.Errorf("got %d calls, want 1", c.calls)
+	}
+
+	// The caller giving up says nothing about SSO, so the breaker stays shut.
+	if _, err := s.IsAdmin(context.Background(), 1); err != nil {
+		t.Fatalf("after cancel: got %v", err)
+	}
+}
+
+func TestSSO_Cancel_Probe(t *testing.T) {
+	c := &client{errs: []error{unavailable}}
+	s := newTestSSO(c, Options{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+	if _, err := s.IsAdmin(context.Background(), 1); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got %v", err)
+	}
+	s.breaker.openUntil = time.Now()
+
+	// The probe is cancelled mid-call, leaving no verdict.
+	c.block = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.IsAdmin(ctx, 1); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("probe: got %v", err)
+	}
+
+	// The next call must be let through as a new probe.
+	c.block = false
+	if _, err := s.IsAdmin(context.Background(), 1); err != nil {
+		t.Fatalf("after cancelled probe: got %v", err)
+	}
+	if c.calls != 3 {
+		t.Errorf("got %d calls, want 3", c.calls)
+	}
+}
+
+func TestSSO_Cache(t *testing.T) {
+	c := &client{}
+	s := newTestSSO(c, Options{CacheTTL: time.Minute})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		user, err := s.UserInfo(ctx, "good")
+		if err != nil || user.GetId() != 1 {
+			t.Fatalf("call %d: got %v, %v", i, user, err)
+		}
+	}
+	if c.calls != 1 {
+		t.Errorf("got %d calls, want 1", c.calls)
+	}
+
+	// Unknown tokens are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := s.UserInfo(ctx, "bad"); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("unknown token: got %v", err)
+		}
+	}
+	if c.calls != 3 {
+		t.Errorf("got %d calls, want 3", c.calls)
+	}
+
+	for key := range s.cache.entries {
+		if key == "user:good" {
+			t.Error("token cached in clear text")
+		}
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	c := newCache(time.Minute)
+	c.set("k", 1)
+	if v, ok := c.get("k"); !ok || v != 1 {
+		t.Fatalf("got %v, %v", v, ok)
+	}
+
+	c.entries["k"] = cacheEntry{value: 1, expires: time.Now().Add(-time.Second)}
+	if _, ok := c.get("k"); ok {
+		t.Error("expired entry returned")
+	}
+	if len(c.entries) != 0 {
+		t.Error("expired entry kept")
+	}
+
+	if _, ok := newCache(0).get("k"); ok {
+		t.Error("disabled cache returned an entry")
+	}
+}
@@ -0,0 +1,179 @@
+package jsonLog
+
+import (
+	"context"
+	"google.golang.org/grpc/grpclog"
+	"io"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlogLevelFatal is the slog level of FATAL entries, above slog.LevelError.
+const SlogLevelFatal = slog.LevelError + 4
+
+// Handler is a slog.Handler writing the same lines as Logger: UTC RFC3339
+// time, attributes flattened into "properties" with dotted group names, and
+// a stack trace on ERROR and above.
+type Handler struct {
+	out   io.Writer
+	level slog.Leveler
+	mu    *sync.Mutex
+
+	prefix     string
+	properties map[string]string
+}
+
+// NewHandler creates a Handler writing records at level or above to out.
+// A nil level means slog.LevelInfo.
+func NewHandler(out io.Writer, level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+
+	return &Handler{
+		out:   out,
+		level: level,
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	e := entry{
+		Level:   levelName(r.Level),
+		Message: r.Message,
+	}
+	if !r.Time.IsZero() {
+		e.Time = r.Time.UTC().Format(time.RFC3339)
+	}
+
+	if len(h.properties) > 0 || r.NumAttrs() > 0 {
+		e.Properties = make(map[string]string, len(h.properties)+r.NumAttrs())
+		for k, v := range h.properties {
+			e.Properties[k] = v
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			flatten(e.Properties, h.prefix, a)
+			return true
+		})
+	}
+
+	if r.Level >= slog.LevelError {
+		e.Trace = string(debug.Stack())
+	}
+
+	_, err := writeEntry(h.mu, h.out, e)
+
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.properties = make(map[string]string, len(h.properties)+len(attrs))
+	for k, v := range h.properties {
+		h2.properties[k] = v
+	}
+	for _, a := range attrs {
+		flatten(h2.properties, h.prefix, a)
+	}
+
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+
+	return &h2
+}
+
+// grpclogLine matches what the grpclog and log packages write: an optional
+// "2006/01/02 15:04:05 " timestamp and an optional severity prefix.
+var grpclogLine = regexp.MustCompile(`^(?:\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? )?(?:(INFO|WARNING|ERROR|FATAL): )?`)
+
+// Write logs a line written by the grpclog or log packages, so the handler
+// can back grpclog.NewLoggerV2 or a log.Logger. The line's own timestamp is
+// dropped and its severity prefix, if any, picks the level; lines without
+// one are logged at ERROR like Logger.Write does.
+func (h *Handler) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+
+	level := slog.LevelError
+	m := grpclogLine.FindStringSubmatch(line)
+	switch m[1] {
+	case "INFO":
+		level = slog.LevelInfo
+	case "WARNING":
+		level = slog.LevelWarn
+	case "FATAL":
+		level = SlogLevelFatal
+	}
+	line = line[len(m[0]):]
+
+	ctx := context.Background()
+	if !h.Enabled(ctx, level) {
+		return len(p), nil
+	}
+
+	if err := h.Handle(ctx, slog.NewRecord(time.Now(), level, line, 0)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// GRPCLogger returns a grpclog.LoggerV2 writing warnings and above through
+// h. grpclog copies error and fatal lines to every writer of lower severity,
+// so h is only the warning writer: each line reaches it exactly once.
+func (h *Handler) GRPCLogger() grpclog.LoggerV2 {
+	return grpclog.NewLoggerV2(io.Discard, h, io.Discard)
+}
+
+func levelName(level slog.Level) string {
+	switch {
+	case level >= SlogLevelFatal:
+		return LevelFatal.String()
+	case level >= slog.LevelError:
+		return LevelError.String()
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return LevelInfo.String()
+	default:
+		return "DEBUG"
+	}
+}
+
+func flatten(dst map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			flatten(dst, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	dst[prefix+a.Key] = a.Value.String()
+}
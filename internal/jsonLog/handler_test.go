@@ -0,0 +1,198 @@
+package jsonLog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/bxiit/order-service-pet-store/internal/jsonLog"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+type line struct {
+	Level      string            `json:"level"`
+	Time       string            `json:"time"`
+	Message    string            `json:"message"`
+	Properties map[string]string `json:"properties"`
+	Trace      string            `json:"trace"`
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []line {
+	t.Helper()
+
+	var out []line
+	for _, s := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if s == "" {
+			continue
+		}
+		var l line
+		if err := json.Unmarshal([]byte(s), &l); err != nil {
+			t.Fatalf("malformed line %q: %v", s, err)
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// unflatten turns a line back into the map slogtest expects, with dotted
+// property names as nested groups.
+func unflatten(l line) map[string]any {
+	m := map[string]any{
+		slog.LevelKey:   l.Level,
+		slog.MessageKey: l.Message,
+	}
+	if l.Time != "" {
+		m[slog.TimeKey] = l.Time
+	}
+
+	for key, value := range l.Properties {
+		path := strings.Split(key, ".")
+		group := m
+		for _, name := range path[:len(path)-1] {
+			sub, ok := group[name].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				group[name] = sub
+			}
+			group = sub
+		}
+		group[path[len(path)-1]] = value
+	}
+
+	return m
+}
+
+func TestHandler_slogtest(t *testing.T) {
+	var buf bytes.Buffer
+	h := jsonLog.NewHandler(&buf, slog.LevelDebug)
+
+	err := slogtest.TestHandler(h, func() []map[string]any {
+		var results []map[string]any
+		for _, l := range lines(t, &buf) {
+			results = append(results, unflatten(l))
+		}
+		return results
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandler_Format(t *testing.T) {
+	var buf bytes.Buffer
+	h := jsonLog.NewHandler(&buf, nil)
+	log := slog.New(h).With(slog.String("env", "prod")).WithGroup("req")
+
+	log.Debug("not logged")
+	log.Info("served", slog.Int("status", 200), slog.Duration("took", time.Second))
+	log.Error("failed")
+
+	got := lines(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("got %d lines, want 2", len(got))
+	}
+
+	info := got[0]
+	want := map[string]string{"env": "prod", "req.status": "200", "req.took": "1s"}
+	if info.Level != "INFO" || info.Message != "served" || info.Trace != "" || !equal(info.Properties, want) {
+		t.Errorf("got %+v", info)
+	}
+	if _, err := time.Parse(time.RFC3339, info.Time); err != nil || !strings.HasSuffix(info.Time, "Z") {
+		t.Errorf("time %q is not UTC RFC 3339", info.Time)
+	}
+
+	if got[1].Level != "ERROR" || got[1].Trace == "" {
+		t.Errorf("got %+v, want an ERROR line with a trace", got[1])
+	}
+}
+
+func TestHandler_Write(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantLevel string
+		wantMsg   string
+	}{
+		{"grpclog info", "2024/05/01 13:07:42 INFO: [core] channel created\n", "INFO", "[core] channel created"},
+		{"grpclog warning", "2024/05/01 13:07:42 WARNING: [transport] closing\n", "WARN", "[transport] closing"},
+		{"grpclog error", "2024/05/01 13:07:42.123456 ERROR: [core] dial failed\n", "ERROR", "[core] dial failed"},
+		{"grpclog fatal", "FATAL: cannot recover\n", "FATAL", "cannot recover"},
+		{"log package", "2024/05/01 13:07:42 http: TLS handshake error\n", "ERROR", "http: TLS handshake error"},
+		{"bare", "something broke", "ERROR", "something broke"},
+		{"severity not at the start", "note: INFO: kept as is\n", "ERROR", "note: INFO: kept as is"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := jsonLog.NewHandler(&buf, slog.LevelDebug)
+
+			n, err := h.Write([]byte(tt.in))
+			if err != nil || n != len(tt.in) {
+				t.Fatalf("got %d, %v", n, err)
+			}
+
+			got := lines(t, &buf)
+			if len(got) != 1 || got[0].Level != tt.wantLevel || got[0].Message != tt.wantMsg {
+				t.Errorf("got %+v, want %s %q", got, tt.wantLevel, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestHandler_Write_Level(t *testing.T) {
+	var buf bytes.Buffer
+	h := jsonLog.NewHandler(&buf, slog.LevelWarn)
+
+	if n, err := h.Write([]byte("INFO: dropped\n")); err != nil || n != 14 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q below the level", buf.String())
+	}
+}
+
+func TestHandler_GRPCLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := jsonLog.NewHandler(&buf, slog.LevelDebug).GRPCLogger()
+
+	logger.Info("connecting")
+	logger.Warning("retrying")
+	logger.Error("dial failed")
+
+	got := lines(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("got %d lines, want each warning and error once: %+v", len(got), got)
+	}
+	if got[0].Level != "WARN" || got[0].Message != "retrying" || got[1].Level != "ERROR" || got[1].Message != "dial failed" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestHandler_Enabled(t *testing.T) {
+	level := new(slog.LevelVar)
+	h := jsonLog.NewHandler(&bytes.Buffer{}, level)
+
+	level.Set(slog.LevelError)
+	if h.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("warn enabled at error level")
+	}
+	if !h.Enabled(context.Background(), jsonLog.SlogLevelFatal) {
+		t.Error("fatal disabled at error level")
+	}
+}
+
+func equal(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -63,14 +63,7 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 	if level < l.minLevel {
 		return 0, nil
 	}
-	// Declare an anonymous struct holding the data for the log entry.
-	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
-	}{
+	e := entry{
 		Level:      level.String(),
 		Time:       time.Now().UTC().Format(time.RFC3339),
 		Message:    message,
@@ -78,24 +71,38 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 	}
 	// Include a stack trace for entries at the ERROR and FATAL levels.
 	if level >= LevelError {
-		aux.Trace = string(debug.Stack())
+		e.Trace = string(debug.Stack())
 	}
+
+	return writeEntry(&l.mu, l.out, e)
+}
+
+// entry is the layout of a log line, shared by Logger and Handler.
+type entry struct {
+	Level      string            `json:"level"`
+	Time       string            `json:"time"`
+	Message    string            `json:"message"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Trace      string            `json:"trace,omitempty"`
+}
+
+func writeEntry(mu *sync.Mutex, out io.Writer, e entry) (int, error) {
 	// Declare a line variable for holding the actual log entry text.
 	var line []byte
-	// Marshal the anonymous struct to JSON and store it in the line variable. If there
+	// Marshal the entry to JSON and store it in the line variable. If there
 	// was a problem creating the JSON, set the contents of the log entry to be that
 	// plain-text error message instead.
-	line, err := json.Marshal(aux)
+	line, err := json.Marshal(e)
 	if err != nil {
 		line = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
 	}
 	// Lock the mutex so that no two writes to the output destination can happen
 	// concurrently. If we don't do this, it's possible that the text for two or more
 	// log entries will be intermingled in the output.
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
 	// Write the log entry followed by a newline.
-	return l.out.Write(append(line, '\n'))
+	return out.Write(append(line, '\n'))
 }
 
 // We also implement a Write() method on our Logger type so that it satisfies the
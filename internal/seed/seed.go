@@ -0,0 +1,339 @@
+// Package seed fills a local database with generated users, items and
+// orders. The users and items live in tables owned by the SSO and catalogue
+// services; seed creates minimal stand-ins for them when they are missing so
+// the migrations and the service can run without those services' databases.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/dto"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// schema creates the schemas and the stub tables the order migrations
+// reference. Existing tables are left alone.
+const schema = `
+CREATE SCHEMA IF NOT EXISTS sso;
+CREATE SCHEMA IF NOT EXISTS catalogue;
+CREATE SCHEMA IF NOT EXISTS order_service;
+
+CREATE TABLE IF NOT EXISTS sso.users (
+    id    BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    email TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS catalogue.item_info (
+    id          BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    name        TEXT    NOT NULL CONSTRAINT item_info_name_key UNIQUE,
+    price       INTEGER NOT NULL,
+    description TEXT    NOT NULL DEFAULT '',
+    quantity    INTEGER NOT NULL DEFAULT 0,
+    image_url   TEXT    NOT NULL DEFAULT ''
+);`
+
+// batchSize is how many rows go into one multi-row insert.
+const batchSize = 1000
+
+type Seeder struct {
+	db *sql.DB
+}
+
+// Options sizes the generated data. Orders are spread over the Days days
+// before End. The same Seed and End always produce the same rows, given the
+// tables were reset before.
+type Options struct {
+	Seed   int64
+	Users  int
+	Items  int
+	Orders int
+	Days   int
+	End    time.Time
+}
+
+// Result counts the rows Seed inserted.
+type Result struct {
+	Users  int
+	Items  int
+	Orders int
+}
+
+func New(db *sql.DB) *Seeder {
+	return &Seeder{db: db}
+}
+
+// EnsureSchema creates the schemas and stub tables when they are missing.
+func (s *Seeder) EnsureSchema(ctx context.Context) error {
+	const op = "seed.EnsureSchema"
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Reset deletes every order, item and user and restarts their ids, leaving
+// the database as freshly migrated.
+func (s *Seeder) Reset(ctx context.Context) error {
+	const op = "seed.Reset"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	tables := []string{"catalogue.item_info", "sso.users"}
+	for _, table := range []string{"order_service.order_quota", "order_service.orders"} {
+		if ok, err := exists(ctx, tx, table); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		} else if ok {
+			tables = append([]string{table}, tables...)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := refreshViews(ctx, tx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Seed generates and inserts users, items and orders in one transaction.
+// The orders table must exist, i.e. the migrations must have run.
+func (s *Seeder) Seed(ctx context.Context, opts Options) (Result, error) {
+	const op = "seed.Seed"
+	fail := func(e error) (Result, error) {
+		return Result{}, fmt.Errorf("%s: %w", op, e)
+	}
+
+	if opts.Orders > 0 && (opts.Users == 0 || opts.Items == 0) {
+		return fail(fmt.Errorf("orders need at least one user and one item"))
+	}
+	if opts.Orders > 0 && opts.Days < 1 {
+		return fail(fmt.Errorf("orders need a span of at least one day"))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	if ok, err := exists(ctx, tx, "order_service.orders"); err != nil {
+		return fail(err)
+	} else if !ok && opts.Orders > 0 {
+		return fail(fmt.Errorf("order_service.orders does not exist, run the migrations first"))
+	}
+
+	ds := Generate(opts)
+
+	users := make([][]interface{}, 0, len(ds.Users))
+	for _, email := range ds.Users {
+		users = append(users, []interface{}{email})
+	}
+	userIds, err := insertRows(ctx, tx, "sso.users", []string{"email"}, users)
+	if err != nil {
+		return fail(err)
+	}
+
+	items := make([][]interface{}, 0, len(ds.Items))
+	for _, item := range ds.Items {
+		items = append(items, []interface{}{item.Name, item.Price, item.Description, item.Quantity, item.ImageURL})
+	}
+	itemIds, err := insertRows(ctx, tx, "catalogue.item_info",
+		[]string{"name", "price", "description", "quantity", "image_url"}, items)
+	if err != nil {
+		return fail(err)
+	}
+
+	orders := make([][]interface{}, 0, len(ds.Orders))
+	for _, order := range ds.Orders {
+		orders = append(orders, []interface{}{userIds[order.UserId-1], itemIds[order.ItemId-1], order.Status, order.CreatedAt})
+	}
+	if _, err := insertRows(ctx, tx, "order_service.orders", []string{"user_id", "item_id", "status", "created_at"}, orders); err != nil {
+		return fail(err)
+	}
+
+	if opts.Orders > 0 {
+		if err := refreshViews(ctx, tx); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+
+	return Result{Users: len(userIds), Items: len(itemIds), Orders: len(orders)}, nil
+}
+
+// insertRows inserts rows with multi-row inserts and returns their ids in
+// order.
+func insertRows(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}) ([]int32, error) {
+	ids := make([]int32, 0, len(rows))
+
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+
+		var query strings.Builder
+		fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+		args := make([]interface{}, 0, len(batch)*len(columns))
+		for i, row := range batch {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					query.WriteString(", ")
+				}
+				args = append(args, v)
+				fmt.Fprintf(&query, "$%d", len(args))
+			}
+			query.WriteByte(')')
+		}
+		query.WriteString(" RETURNING id")
+
+		result, err := tx.QueryContext(ctx, query.String(), args...)
+		if err != nil {
+			return nil, fmt.Errorf("inserting into %s: %w", table, err)
+		}
+		for result.Next() {
+			var id int32
+			if err := result.Scan(&id); err != nil {
+				result.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
+
+func exists(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	var ok bool
+	err := tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&ok)
+	return ok, err
+}
+
+// refreshViews brings the report views, if migrated, in line with the data.
+func refreshViews(ctx context.Context, tx *sql.Tx) error {
+	ok, err := exists(ctx, tx, "order_service.daily_sales")
+	if err != nil || !ok {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `REFRESH MATERIALIZED VIEW order_service.daily_sales`)
+	return err
+}
+
+// Dataset is generated data ready to be stored. Orders refer to users and
+// items by their 1-based position in Users and Items, since the ids are only
+// known once those are stored.
+type Dataset struct {
+	Users  []string
+	Items  []dto.ItemDTO
+	Orders []models.Order
+}
+
+// Generate makes the data Seed stores, without touching any database. It is
+// deterministic: the same options give the same dataset.
+func Generate(opts Options) *Dataset {
+	gen := &generator{rnd: rand.New(rand.NewSource(opts.Seed))}
+
+	return &Dataset{
+		Users:  gen.users(opts.Users),
+		Items:  gen.items(opts.Items),
+		Orders: gen.orders(opts.Orders, opts.Users, opts.Items, opts.End, opts.Days),
+	}
+}
+
+// generator makes plausible rows from a seeded source.
+type generator struct {
+	rnd *rand.Rand
+}
+
+var (
+	firstNames = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy", "mallory", "nurlan", "aigerim", "dana", "yerlan", "madina", "oscar", "peggy", "sybil", "trent"}
+	lastNames  = []string{"smith", "johnson", "lee", "brown", "garcia", "miller", "davis", "akhmetov", "suleimenova", "ivanov", "kim", "nowak", "rossi", "muller", "silva"}
+	domains    = []string{"example.com", "example.org", "example.net"}
+
+	brands    = []string{"Happy Paws", "Whisker Co", "Barkley", "Feather & Fin", "NutriPet", "Tail Waggers", "PurrFect", "Wild Trail"}
+	qualities = []string{"Organic", "Grain-Free", "Premium", "Deluxe", "Eco", "Classic", "Orthopedic", "Interactive"}
+	products  = []string{"Salmon Cat Food", "Chicken Dog Food", "Bird Seed Mix", "Aquarium Filter", "Chew Toy", "Scratching Post", "Pet Bed", "Leash", "Litter", "Hamster Wheel", "Fish Flakes", "Dental Treats"}
+	features  = []string{"vet approved", "made from natural ingredients", "easy to clean", "built to last", "loved by picky eaters", "sustainably sourced"}
+)
+
+func (g *generator) pick(from []string) string {
+	return from[g.rnd.Intn(len(from))]
+}
+
+func (g *generator) users(n int) []string {
+	users := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		users = append(users, fmt.Sprintf("%s.%s%d@%s", g.pick(firstNames), g.pick(lastNames), i+1, g.pick(domains)))
+	}
+	return users
+}
+
+func (g *generator) items(n int) []dto.ItemDTO {
+	items := make([]dto.ItemDTO, 0, n)
+	for i := 0; i < n; i++ {
+		product := g.pick(products)
+		first := g.rnd.Intn(len(features))
+		second := (first + 1 + g.rnd.Intn(len(features)-1)) % len(features)
+		items = append(items, dto.ItemDTO{
+			// The running number keeps names unique however many items are made.
+			Name:        fmt.Sprintf("%s %s %s #%d", g.pick(brands), g.pick(qualities), product, i+1),
+			Price:       int32(100 + g.rnd.Intn(200)*50),
+			Description: fmt.Sprintf("%s, %s and %s.", product, features[first], features[second]),
+			Quantity:    int32(g.rnd.Intn(500)),
+			ImageURL:    fmt.Sprintf("https://images.example.com/items/%d.jpg", i+1),
+		})
+	}
+	return items
+}
+
+// orders picks users uniformly and items with a long tail, so a few items
+// sell much better than the rest, and cancels about one order in ten.
+func (g *generator) orders(n, users, items int, end time.Time, days int) []models.Order {
+	if n == 0 || users == 0 || items == 0 || days < 1 {
+		return nil
+	}
+
+	popularity := rand.NewZipf(g.rnd, 1.2, 1, uint64(items-1))
+	span := time.Duration(days) * 24 * time.Hour
+
+	orders := make([]models.Order, 0, n)
+	for i := 0; i < n; i++ {
+		status := models.StatusCreated
+		if g.rnd.Intn(10) == 0 {
+			status = models.StatusCancelled
+		}
+		orders = append(orders, models.Order{
+			UserId:    int32(g.rnd.Intn(users) + 1),
+			ItemId:    int32(popularity.Uint64() + 1),
+			Status:    status,
+			CreatedAt: end.Add(-time.Duration(g.rnd.Int63n(int64(span)))).Truncate(time.Second),
+		})
+	}
+	return orders
+}
@@ -0,0 +1,198 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Row is one order read from the input. Err is set when the record could not
+// be turned into an order; such rows are reported and skipped.
+type Row struct {
+	// Line is the 1-based input line the record starts on, or the
+	// position of the message in an import stream.
+	Line  int
+	Order models.Order
+	Err   *RowError
+}
+
+// Source yields rows until it returns io.EOF. Any other error aborts the
+// import.
+type Source interface {
+	Next() (Row, error)
+}
+
+// NewDecoder returns a Source reading CSV with a header row or JSON Lines.
+// Recognised fields are user_id, item_id, status and created_at; status
+// defaults to created and created_at, an RFC 3339 time, to the import time.
+func NewDecoder(format string, r io.Reader) (Source, error) {
+	switch format {
+	case FormatCSV:
+		return &csvDecoder{r: csv.NewReader(r)}, nil
+	case FormatJSONL:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &jsonlDecoder{sc: sc}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	header []string
+}
+
+func (d *csvDecoder) Next() (Row, error) {
+	if d.header == nil {
+		header, err := d.r.Read()
+		if err == io.EOF {
+			return Row{}, io.EOF
+		}
+		if err != nil {
+			return Row{}, fmt.Errorf("reading header: %w", err)
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+		d.header = header
+		d.r.FieldsPerRecord = len(header)
+	}
+
+	record, err := d.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Row{Line: parseErr.StartLine, Err: &RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()}}, nil
+		}
+		return Row{}, err
+	}
+	line, _ := d.r.FieldPos(0)
+
+	fields := make(map[string]interface{}, len(record))
+	for i, value := range record {
+		if value != "" {
+			fields[d.header[i]] = value
+		}
+	}
+
+	return RowFromFields(line, fields), nil
+}
+
+type jsonlDecoder struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (d *jsonlDecoder) Next() (Row, error) {
+	for d.sc.Scan() {
+		d.line++
+		text := strings.TrimSpace(d.sc.Text())
+		if text == "" {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return Row{Line: d.line, Err: &RowError{Line: d.line, Message: "malformed JSON: " + err.Error()}}, nil
+		}
+
+		return RowFromFields(d.line, fields), nil
+	}
+	if err := d.sc.Err(); err != nil {
+		return Row{}, err
+	}
+
+	return Row{}, io.EOF
+}
+
+// RowFromFields builds the row at line from decoded fields. Ids may be JSON
+// numbers or decimal strings, as CSV gives them.
+func RowFromFields(line int, fields map[string]interface{}) Row {
+	row := Row{
+		Line: line,
+		Order: models.Order{
+			Status: models.StatusCreated,
+		},
+	}
+	fail := func(field, msg string) Row {
+		row.Err = &RowError{Line: line, Field: field, Message: msg}
+		return row
+	}
+
+	for name, value := range fields {
+		var ok bool
+		switch name {
+		case "user_id":
+			row.Order.UserId, ok = id(value)
+			if !ok {
+				return fail(name, "must be a positive integer")
+			}
+		case "item_id":
+			row.Order.ItemId, ok = id(value)
+			if !ok {
+				return fail(name, "must be a positive integer")
+			}
+		case "status":
+			row.Order.Status, ok = value.(string)
+			if !ok || (row.Order.Status != models.StatusCreated && row.Order.Status != models.StatusCancelled) {
+				return fail(name, fmt.Sprintf("must be %s or %s", models.StatusCreated, models.StatusCancelled))
+			}
+		case "created_at":
+			s, _ := value.(string)
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fail(name, "must be an RFC 3339 time")
+			}
+			row.Order.CreatedAt = t
+		default:
+			return fail(name, "is not a known field")
+		}
+	}
+
+	switch {
+	case row.Order.UserId == 0:
+		return fail("user_id", "is required")
+	case row.Order.ItemId == 0:
+		return fail("item_id", "is required")
+	}
+
+	return row
+}
+
+func id(v interface{}) (int32, bool) {
+	var n float64
+	switch v := v.(type) {
+	case float64:
+		n = v
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		n = float64(i)
+	default:
+		return 0, false
+	}
+
+	if n < 1 || n > math.MaxInt32 || n != math.Trunc(n) {
+		return 0, false
+	}
+	return int32(n), true
+}
@@ -0,0 +1,251 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// DefaultBatchSize is how many rows are validated and inserted together.
+const DefaultBatchSize = 500
+
+// MaxReportErrors bounds Report.Errors so the report of a huge import still
+// fits in a response. Failed keeps counting past it.
+const MaxReportErrors = 1000
+
+// MaxBatchSize keeps a batch insert well under the PostgreSQL limit of
+// 65535 bind parameters.
+const MaxBatchSize = 10000
+
+type Importer struct {
+	log       *slog.Logger
+	repo      ImportRepo
+	batchSize int
+	now       func() time.Time
+}
+
+type ImportRepo interface {
+	ExistingUsers(ctx context.Context, ids []int32) (map[int32]bool, error)
+	ExistingItems(ctx context.Context, ids []int32) (map[int32]bool, error)
+	ImportOrders(ctx context.Context, orders []*models.Order) error
+}
+
+// RowError explains why the row at Line was not imported. Field is empty
+// when the problem is not tied to one field.
+type RowError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *RowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s %s", e.Line, e.Field, e.Message)
+}
+
+// Report sums up an import. In a dry run Imported counts the rows that
+// would have been imported.
+//
+// Processed is the last input line whose outcome is final: its batch was
+// committed or it was rejected. When an import aborts, the rows up to it are
+// stored or reported and a retry should resume after it.
+type Report struct {
+	Rows            int         `json:"rows"`
+	Imported        int         `json:"imported"`
+	Failed          int         `json:"failed"`
+	Processed       int         `json:"processed"`
+	DryRun          bool        `json:"dry_run"`
+	Errors          []*RowError `json:"errors,omitempty"`
+	ErrorsTruncated bool        `json:"errors_truncated,omitempty"`
+}
+
+// New creates an importer inserting batchSize rows at a time; zero means
+// DefaultBatchSize.
+func New(
+	log *slog.Logger,
+	repo ImportRepo,
+	batchSize int,
+) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Importer{
+		log:       log,
+		repo:      repo,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+// Import reads every row from src, checks that its user and item exist and
+// inserts the valid ones batch by batch, each batch in its own transaction.
+// Invalid rows are reported and skipped without failing the rest. Only a
+// failure to read the input or to reach the database aborts the import; the
+// report then covers the batches handled so far, see Report.Processed.
+func (im *Importer) Import(ctx context.Context, src Source, dryRun bool) (*Report, error) {
+	const op = "Importer.Import"
+
+	log := im.log.With(slog.String("op", op), slog.Bool("dry_run", dryRun))
+	report := &Report{DryRun: dryRun}
+
+	batch := make([]Row, 0, im.batchSize)
+	line := 0
+	flush := func() error {
+		if err := im.importBatch(ctx, batch, dryRun, report); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		report.Processed = line
+		return nil
+	}
+
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Error("failed to read import", sl.Err(err))
+			return report, fmt.Errorf("%s: %w", op, err)
+		}
+
+		report.Rows++
+		line = row.Line
+		if row.Err != nil {
+			report.fail(row.Err)
+			if len(batch) == 0 {
+				report.Processed = line
+			}
+			continue
+		}
+
+		batch = append(batch, row)
+		if len(batch) == im.batchSize {
+			if err := flush(); err != nil {
+				log.Error("failed to import batch", sl.Err(err))
+				return report, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		log.Error("failed to import batch", sl.Err(err))
+		return report, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Decoding errors are found before batch errors; report them in input
+	// order.
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Line < report.Errors[j].Line
+	})
+
+	log.Info("import finished",
+		slog.Int("rows", report.Rows),
+		slog.Int("imported", report.Imported),
+		slog.Int("failed", report.Failed),
+	)
+
+	return report, nil
+}
+
+func (im *Importer) importBatch(ctx context.Context, batch []Row, dryRun bool, report *Report) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	userIds := make([]int32, 0, len(batch))
+	itemIds := make([]int32, 0, len(batch))
+	for _, row := range batch {
+		userIds = append(userIds, row.Order.UserId)
+		itemIds = append(itemIds, row.Order.ItemId)
+	}
+	users, err := im.repo.ExistingUsers(ctx, unique(userIds))
+	if err != nil {
+		return err
+	}
+	items, err := im.repo.ExistingItems(ctx, unique(itemIds))
+	if err != nil {
+		return err
+	}
+
+	now := im.now()
+	var orders []*models.Order
+	var lines []int
+	for _, row := range batch {
+		switch {
+		case !users[row.Order.UserId]:
+			report.fail(&RowError{Line: row.Line, Field: "user_id", Message: "does not exist"})
+			continue
+		case !items[row.Order.ItemId]:
+			report.fail(&RowError{Line: row.Line, Field: "item_id", Message: "does not exist"})
+			continue
+		}
+
+		order := row.Order
+		if order.CreatedAt.IsZero() {
+			order.CreatedAt = now
+		}
+		orders = append(orders, &order)
+		lines = append(lines, row.Line)
+	}
+
+	if dryRun {
+		report.Imported += len(orders)
+		return nil
+	}
+
+	err = im.repo.ImportOrders(ctx, orders)
+	if err == nil {
+		report.Imported += len(orders)
+		return nil
+	}
+	if !errors.Is(err, data.ErrRejected) {
+		return err
+	}
+
+	// Something in the batch was rejected after all, e.g. a user deleted
+	// meanwhile. Retry row by row so only the culprits are reported.
+	for i, order := range orders {
+		if err := im.repo.ImportOrders(ctx, []*models.Order{order}); err != nil {
+			if !errors.Is(err, data.ErrRejected) {
+				return err
+			}
+			report.fail(&RowError{Line: lines[i], Message: data.ErrRejected.Error()})
+			im.log.Warn("import row rejected", slog.Int("line", lines[i]), sl.Err(err))
+			continue
+		}
+		report.Imported++
+	}
+
+	return nil
+}
+
+func (r *Report) fail(err *RowError) {
+	r.Failed++
+	if len(r.Errors) == MaxReportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, err)
+}
+
+func unique(ids []int32) []int32 {
+	seen := make(map[int32]bool, len(ids))
+	out := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repo knows users 1 and 2 and items 1 and 2. ImportOrders fails with
+// failAt from that call on, and rejects any order for item 2.
+type repo struct {
+	calls    int
+	failAt   int
+	imported []*models.Order
+}
+
+var errDown = errors.New("connection refused")
+
+func (r *repo) ExistingUsers(_ context.Context, ids []int32) (map[int32]bool, error) {
+	return map[int32]bool{1: true, 2: true}, nil
+}
+
+func (r *repo) ExistingItems(_ context.Context, ids []int32) (map[int32]bool, error) {
+	return map[int32]bool{1: true, 2: true}, nil
+}
+
+func (r *repo) ImportOrders(_ context.Context, orders []*models.Order) error {
+	r.calls++
+	if r.failAt > 0 && r.calls >= r.failAt {
+		return errDown
+	}
+	for _, o := range orders {
+		if o.ItemId == 2 {
+			return data.ErrRejected
+		}
+	}
+	r.imported = append(r.imported, orders...)
+	return nil
+}
+
+func newTestImporter(r *repo, batchSize int) *Importer {
+	im := New(slog.New(slog.NewTextHandler(io.Discard, nil)), r, batchSize)
+	im.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	return im
+}
+
+func decode(t *testing.T, jsonl string) Source {
+	t.Helper()
+	src, err := NewDecoder(FormatJSONL, strings.NewReader(jsonl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestImport(t *testing.T) {
+	r := &repo{}
+	src := decode(t, `{"user_id": 1, "item_id": 1}
+{"user_id": 3, "item_id": 1}
+{"user_id": "x"}
+{"user_id": 2, "item_id": 2}
+{"user_id": 2, "item_id": 1, "status": "cancelled", "created_at": "2023-05-01T10:00:00Z"}
+`)
+
+	report, err := newTestImporter(r, 2).Import(context.Background(), src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "rows 5, imported 2, failed 3, processed 5: line 2: user_id does not exist; line 3: user_id must be a positive integer; line 4: " + data.ErrRejected.Error()
+	if got := describe(report); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if len(r.imported) != 2 || !r.imported[0].CreatedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || r.imported[1].Status != models.StatusCancelled {
+		t.Errorf("imported %+v", r.imported)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	r := &repo{}
+	src := decode(t, "{\"user_id\": 1, \"item_id\": 1}\n{\"user_id\": 9, \"item_id\": 1}\n")
+
+	report, err := newTestImporter(r, 10).Import(context.Background(), src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Failed != 1 || !report.DryRun || r.calls != 0 {
+		t.Errorf("got %s after %d calls", describe(report), r.calls)
+	}
+}
+
+func TestImport_Aborted(t *testing.T) {
+	var lines []string
+	for i := 0; i < 7; i++ {
+		lines = append(lines, `{"user_id": 1, "item_id": 1}`)
+	}
+	lines[4] = `{"user_id": 0}`
+
+	// Batches of two: lines 1-2 and 3-4 (line 5 is invalid) commit, the
+	// batch of lines 6-7 fails.
+	r := &repo{failAt: 3}
+	report, err := newTestImporter(r, 2).Import(context.Background(), decode(t, strings.Join(lines, "\n")), false)
+	if !errors.Is(err, errDown) {
+		t.Fatalf("got %v", err)
+	}
+	if report.Imported != 4 || report.Processed != 5 || report.Rows != 7 || report.Failed != 1 {
+		t.Errorf("got %s", describe(report))
+	}
+}
+
+func TestImport_ErrorsCapped(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < MaxReportErrors+10; i++ {
+		b.WriteString("{\"user_id\": \"bad\"}\n")
+	}
+
+	report, err := newTestImporter(&repo{}, 10).Import(context.Background(), decode(t, b.String()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed != MaxReportErrors+10 || len(report.Errors) != MaxReportErrors || !report.ErrorsTruncated {
+		t.Errorf("got %d failed, %d errors, truncated %v", report.Failed, len(report.Errors), report.ErrorsTruncated)
+	}
+}
+
+func describe(r *Report) string {
+	var errs []string
+	for _, e := range r.Errors {
+		errs = append(errs, e.Error())
+	}
+	return fmt.Sprintf("rows %d, imported %d, failed %d, processed %d: %s", r.Rows, r.Imported, r.Failed, r.Processed, strings.Join(errs, "; "))
+}
@@ -0,0 +1,409 @@
+package order_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/config"
+	"github.com/bxiit/order-service-pet-store/internal/data"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/domain"
+	"github.com/bxiit/order-service-pet-store/internal/services/order"
+	"github.com/bxiit/order-service-pet-store/internal/settings"
+	cataloguev20 "github.com/bxiit/protos/gen/go/catalogue"
+	ssov1 "github.com/bxiit/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// repo is an OrderRepo whose methods tests set as needed. Unset methods
+// fail the call.
+type repo struct {
+	saveOrder func(*domain.Order) (*domain.Order, error)
+	quotaErr  error
+	getOrder  func(id int) (*domain.Order, error)
+	cancel    func(id int) error
+	saved     []*domain.Order
+	quotas    []models.OrderQuota
+}
+
+var errUnexpected = errors.New("unexpected call")
+
+func (r *repo) SaveOrderWithinQuota(_ context.Context, o *domain.Order, quota models.OrderQuota) (*domain.Order, error) {
+	r.quotas = append(r.quotas, quota)
+	if r.quotaErr != nil {
+		return nil, r.quotaErr
+	}
+	r.saved = append(r.saved, o)
+	if r.saveOrder == nil {
+		saved := *o
+		saved.ID = int32(len(r.saved))
+		saved.Lines = []domain.Line{{Item: domain.Item{ID: o.Lines[0].Item.ID, Name: "bowl", Price: 450}, Quantity: 1}}
+		return &saved, nil
+	}
+	return r.saveOrder(o)
+}
+
+func (r *repo) GetAllOrders(_ context.Context, filter models.OrderFilter) ([]*domain.Order, error) {
+	return []*domain.Order{{ID: 1, UserId: filter.UserId}}, nil
+}
+
+func (r *repo) GetOrderById(_ context.Context, id int) (*domain.Order, error) {
+	if r.getOrder == nil {
+		return nil, errUnexpected
+	}
+	return r.getOrder(id)
+}
+
+func (r *repo) GetOrdersByUserId(_ context.Context, userId int) ([]*domain.Order, error) {
+	return []*domain.Order{{ID: 1, UserId: int32(userId)}}, nil
+}
+
+func (r *repo) CancelOrder(_ context.Context, id int) error {
+	if r.cancel == nil {
+		return errUnexpected
+	}
+	return r.cancel(id)
+}
+
+func (r *repo) SearchOrders(_ context.Context, filter models.SearchFilter) ([]*domain.Order, int, error) {
+	return []*domain.Order{{ID: 1}}, 7, nil
+}
+
+// identity knows one token.
+type identity struct {
+	token string
+	user  *ssov1.User
+	err   error
+}
+
+func (i *identity) UserInfo(_ context.Context, token string) (*ssov1.User, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+	if token != i.token {
+		return nil, status.Error(codes.Unauthenticated, "unknown token")
+	}
+	return i.user, nil
+}
+
+func (i *identity) CachedUserInfo(token string) (*ssov1.User, bool) {
+	user, err := i.UserInfo(context.Background(), token)
+	return user, err == nil
+}
+
+func (i *identity) IsAdmin(context.Context, int64) (bool, error) {
+	return false, nil
+}
+
+func (i *identity) IsAuthenticated(_ context.Context, token string) (bool, error) {
+	return token == i.token, nil
+}
+
+// catalogue answers GetItem from a set of item ids.
+type catalogue struct {
+	cataloguev20.CatalogueServiceClient
+	items map[string]bool
+	err   error
+	calls []string
+}
+
+func (c *catalogue) GetItem(_ context.Context, in *cataloguev20.GetItemRequest, _ ...grpc.CallOption) (*cataloguev20.GetItemResponse, error) {
+	c.calls = append(c.calls, in.GetId())
+	if c.err != nil {
+		return nil, c.err
+	}
+	if !c.items[in.GetId()] {
+		return nil, status.Error(codes.NotFound, "item not found")
+	}
+	return &cataloguev20.GetItemResponse{Item: &cataloguev20.Item{}}, nil
+}
+
+type publisher struct {
+	events [][]byte
+	err    error
+}
+
+func (p *publisher) Publish(_ context.Context, body []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, body)
+	return nil
+}
+
+type fixture struct {
+	repo      *repo
+	identity  *identity
+	catalogue *catalogue
+	publisher *publisher
+	cfg       *config.Config
+}
+
+func newFixture() *fixture {
+	return &fixture{
+		repo:      &repo{},
+		identity:  &identity{token: "t0ken", user: &ssov1.User{Id: 7, Email: "a@example.com"}},
+		catalogue: &catalogue{items: map[string]bool{"3": true, "4": true}},
+		publisher: &publisher{},
+		cfg:       &config.Config{Env: "local", Features: map[string]bool{settings.FeatureCatalogueCheck: true}},
+	}
+}
+
+func (f *fixture) service() *order.Order {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return order.New(log, f.repo, f.identity, f.catalogue, settings.New(f.cfg, new(slog.LevelVar)), f.publisher, time.Hour)
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", token))
+}
+
+func TestCreateOrder(t *testing.T) {
+	f := newFixture()
+
+	got, err := f.service().CreateOrder(withToken("t0ken"), domain.NewOrder(7, domain.Item{ID: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 1 || got.Status != domain.StatusCreated || got.Total() != 450 {
+		t.Errorf("got %+v", got)
+	}
+	if !reflect.DeepEqual(f.catalogue.calls, []string{"3"}) {
+		t.Errorf("catalogue asked for %v", f.catalogue.calls)
+	}
+
+	if len(f.publisher.events) != 1 {
+		t.Fatalf("published %d events", len(f.publisher.events))
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(f.publisher.events[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	orderInfo := event["order_info"].(map[string]interface{})
+	want := map[string]interface{}{
+		"id":         1.0,
+		"user_id":    7.0,
+		"item_id":    3.0,
+		"status":     domain.StatusCreated,
+		"created_at": "0001-01-01T00:00:00Z",
+		"item":       map[string]interface{}{"id": 3.0, "name": "bowl", "price": 450.0, "image_url": ""},
+	}
+	if !reflect.DeepEqual(orderInfo, want) {
+		t.Errorf("got order_info %v, want %v", orderInfo, want)
+	}
+	if user := event["user_info"].(map[string]interface{}); user["id"] != 7.0 {
+		t.Errorf("got user_info %v", user)
+	}
+}
+
+func TestCreateOrder_Lines(t *testing.T) {
+	f := newFixture()
+	f.cfg.Features[settings.FeatureNotifications] = false
+	f.repo.saveOrder = func(o *domain.Order) (*domain.Order, error) { return o, nil }
+
+	_, err := f.service().CreateOrder(context.Background(), domain.NewOrder(7, domain.Item{ID: 3}, domain.Item{ID: 5}))
+	if !errors.Is(err, order.ErrItemNotFound) {
+		t.Errorf("got %v, want ErrItemNotFound", err)
+	}
+	if !reflect.DeepEqual(f.catalogue.calls, []string{"3", "5"}) || len(f.repo.saved) != 0 {
+		t.Errorf("catalogue asked for %v, saved %d orders", f.catalogue.calls, len(f.repo.saved))
+	}
+}
+
+func TestCreateOrder_Quota(t *testing.T) {
+	f := newFixture()
+	f.cfg.RateLimits.DailyOrderQuota = 2
+
+	// The quota is the caller's, user 7, not that of the user ordered for.
+	if _, err := f.service().CreateOrder(withToken("t0ken"), domain.NewOrder(8, domain.Item{ID: 3})); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.OrderQuota{{CallerId: 7, Limit: 2, Day: time.Now().UTC().Truncate(24 * time.Hour)}}
+	if !reflect.DeepEqual(f.repo.quotas, want) {
+		t.Errorf("got quotas %+v, want %+v", f.repo.quotas, want)
+	}
+
+	f = newFixture()
+	if _, err := f.service().CreateOrder(context.Background(), domain.NewOrder(8, domain.Item{ID: 3})); err == nil {
+		// Saved, but publishing needs a token.
+		t.Error("no error")
+	}
+	if !reflect.DeepEqual(f.repo.quotas, []models.OrderQuota{{}}) {
+		t.Errorf("without a quota got %+v", f.repo.quotas)
+	}
+}
+
+func TestCreateOrder_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		setup   func(f *fixture)
+		wantErr error
+		saved   int
+	}{
+		{
+			name:    "unknown item",
+			setup:   func(f *fixture) { f.catalogue.items = nil },
+			wantErr: order.ErrItemNotFound,
+		},
+		{
+			name:  "catalogue down",
+			setup: func(f *fixture) { f.catalogue.err = status.Error(codes.Unavailable, "down") },
+		},
+		{
+			name: "quota used up",
+			setup: func(f *fixture) {
+				f.cfg.RateLimits.DailyOrderQuota = 2
+				f.repo.quotaErr = fmt.Errorf("data.SaveOrder: %w", data.ErrQuotaExceeded)
+			},
+			wantErr: order.ErrQuotaExceeded,
+		},
+		{
+			name:  "quota without a token",
+			ctx:   context.Background(),
+			setup: func(f *fixture) { f.cfg.RateLimits.DailyOrderQuota = 2 },
+		},
+		{
+			name:  "quota of an unknown caller",
+			ctx:   withToken("forged"),
+			setup: func(f *fixture) { f.cfg.RateLimits.DailyOrderQuota = 2 },
+		},
+		{
+			name: "save failing",
+			setup: func(f *fixture) {
+				f.repo.saveOrder = func(*domain.Order) (*domain.Order, error) { return nil, errors.New("db down") }
+			},
+			saved: 1,
+		},
+		{
+			name:  "no token for the event",
+			ctx:   context.Background(),
+			saved: 1,
+		},
+		{
+			name:  "unknown token",
+			ctx:   withToken("forged"),
+			saved: 1,
+		},
+		{
+			name:  "publish failing",
+			setup: func(f *fixture) { f.publisher.err = errors.New("broker down") },
+			saved: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = withToken("t0ken")
+			}
+
+			_, err := f.service().CreateOrder(ctx, domain.NewOrder(7, domain.Item{ID: 3}))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if len(f.repo.saved) != tt.saved {
+				t.Errorf("saved %d orders, want %d", len(f.repo.saved), tt.saved)
+			}
+		})
+	}
+}
+
+func TestCreateOrder_FeaturesOff(t *testing.T) {
+	f := newFixture()
+	f.cfg.Features = map[string]bool{
+		settings.FeatureCatalogueCheck: false,
+		settings.FeatureNotifications:  false,
+	}
+	f.catalogue.items = nil
+
+	if _, err := f.service().CreateOrder(context.Background(), domain.NewOrder(7, domain.Item{ID: 3})); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.catalogue.calls) != 0 || len(f.publisher.events) != 0 {
+		t.Errorf("catalogue called %d times, %d events published", len(f.catalogue.calls), len(f.publisher.events))
+	}
+}
+
+func TestCreateOrder_CatalogueCheckOffByDefault(t *testing.T) {
+	f := newFixture()
+	f.cfg.Features = nil
+
+	if _, err := f.service().CreateOrder(withToken("t0ken"), domain.NewOrder(7, domain.Item{ID: 9})); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.catalogue.calls) != 0 {
+		t.Errorf("catalogue asked for %v", f.catalogue.calls)
+	}
+}
+
+func TestCreateOrder_NoCatalogue(t *testing.T) {
+	f := newFixture()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := order.New(log, f.repo, f.identity, nil, settings.New(f.cfg, new(slog.LevelVar)), f.publisher, time.Hour)
+
+	if _, err := s.CreateOrder(withToken("t0ken"), domain.NewOrder(7, domain.Item{ID: 99})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	f := newFixture()
+	f.repo.getOrder = func(id int) (*domain.Order, error) {
+		if id != 5 {
+			return nil, data.ErrRecordNotFound
+		}
+		return &domain.Order{ID: 5}, nil
+	}
+	s := f.service()
+
+	if got, err := s.GetOrder(context.Background(), 5); err != nil || got.ID != 5 {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := s.GetOrder(context.Background(), 6); !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	for _, want := range []error{nil, data.ErrRecordNotFound, data.ErrOrderCancelled} {
+		f := newFixture()
+		f.repo.cancel = func(int) error { return want }
+
+		if err := f.service().CancelOrder(context.Background(), 5); !errors.Is(err, want) {
+			t.Errorf("got %v, want %v", err, want)
+		}
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	s := newFixture().service()
+	ctx := context.Background()
+
+	if got, err := s.ListOrders(ctx, models.OrderFilter{UserId: 7}); err != nil || len(got) != 1 || got[0].UserId != 7 {
+		t.Errorf("ListOrders: got %v, %v", got, err)
+	}
+	if got, err := s.GetOrdersByUserId(ctx, 7); err != nil || len(got) != 1 || got[0].UserId != 7 {
+		t.Errorf("GetOrdersByUserId: got %v, %v", got, err)
+	}
+	if got, total, err := s.SearchOrders(ctx, models.SearchFilter{Query: "bowl"}); err != nil || len(got) != 1 || total != 7 {
+		t.Errorf("SearchOrders: got %v, %d, %v", got, total, err)
+	}
+}
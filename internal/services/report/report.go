@@ -0,0 +1,147 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"github.com/bxiit/order-service-pet-store/internal/data/models"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Report struct {
+	log      *slog.Logger
+	provider ReportRepo
+}
+
+type ReportRepo interface {
+	SalesByPeriod(context.Context, models.ReportFilter) ([]models.PeriodSales, error)
+	TopItems(context.Context, models.ReportFilter) ([]models.ItemSales, error)
+	SalesByUser(context.Context, models.ReportFilter) ([]models.UserSales, error)
+	RefreshReportViews(context.Context) error
+}
+
+func New(
+	log *slog.Logger,
+	provider ReportRepo,
+) *Report {
+	return &Report{
+		log:      log,
+		provider: provider,
+	}
+}
+
+func (r *Report) SalesByPeriod(ctx context.Context, filter models.ReportFilter) ([]models.PeriodSales, error) {
+	const op = "Report.SalesByPeriod"
+
+	sales, err := r.provider.SalesByPeriod(ctx, filter)
+	if err != nil {
+		r.log.Warn("failed to build sales report", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+func (r *Report) TopItems(ctx context.Context, filter models.ReportFilter) ([]models.ItemSales, error) {
+	const op = "Report.TopItems"
+
+	items, err := r.provider.TopItems(ctx, filter)
+	if err != nil {
+		r.log.Warn("failed to build top items report", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+func (r *Report) SalesByUser(ctx context.Context, filter models.ReportFilter) ([]models.UserSales, error) {
+	const op = "Report.SalesByUser"
+
+	users, err := r.provider.SalesByUser(ctx, filter)
+	if err != nil {
+		r.log.Warn("failed to build user report", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// Refresher recomputes the report views every interval until stopped.
+type Refresher struct {
+	log      *slog.Logger
+	provider ReportRepo
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func NewRefresher(log *slog.Logger, provider ReportRepo, interval time.Duration) *Refresher {
+	return &Refresher{
+		log:      log,
+		provider: provider,
+		interval: interval,
+	}
+}
+
+// Start refreshes the views once in the background, then on every tick.
+func (r *Refresher) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.refresh(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop interrupts a running refresh and waits for it until ctx is done.
+func (r *Refresher) Stop(ctx context.Context) error {
+	const op = "report.Refresher.Stop"
+
+	r.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		r.done.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+func (r *Refresher) refresh(ctx context.Context) {
+	const op = "report.Refresher.refresh"
+
+	start := time.Now()
+	if err := r.provider.RefreshReportViews(ctx); err != nil {
+		if ctx.Err() == nil {
+			r.log.Warn("failed to refresh report views", slog.String("op", op), sl.Err(err))
+		}
+		return
+	}
+
+	r.log.Debug("report views refreshed", slog.String("op", op), slog.Duration("took", time.Since(start)))
+}
@@ -0,0 +1,107 @@
+package settings
+
+import (
+	"github.com/bxiit/order-service-pet-store/config"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Feature flag names understood by the service.
+const (
+	FeatureNotifications  = "notifications"   // publish order events to AMQP
+	FeatureCatalogueCheck = "catalogue_check" // verify items against the catalogue before saving; off by default
+)
+
+var defaultFeatures = map[string]bool{
+	FeatureNotifications:  true,
+	FeatureCatalogueCheck: false,
+}
+
+// Settings holds the part of the configuration that can be swapped at runtime
+// on SIGHUP. Readers always see one complete snapshot.
+type Settings struct {
+	level   *slog.LevelVar
+	current atomic.Pointer[snapshot]
+}
+
+type snapshot struct {
+	config     *config.Config
+	auth       config.AuthConfig
+	features   map[string]bool
+	rateLimits config.RateLimitConfig
+}
+
+func New(cfg *config.Config, level *slog.LevelVar) *Settings {
+	s := &Settings{level: level}
+	s.Apply(cfg)
+
+	return s
+}
+
+// Apply publishes the reloadable fields of cfg.
+func (s *Settings) Apply(cfg *config.Config) {
+	features := make(map[string]bool, len(defaultFeatures)+len(cfg.Features))
+	for name, on := range defaultFeatures {
+		features[name] = on
+	}
+	for name, on := range cfg.Features {
+		features[name] = on
+	}
+
+	s.current.Store(&snapshot{
+		config:     cfg,
+		auth:       cfg.Auth,
+		features:   features,
+		rateLimits: cfg.RateLimits,
+	})
+
+	s.level.Set(Level(cfg))
+}
+
+// Config returns the whole config last applied.
+func (s *Settings) Config() *config.Config {
+	return s.current.Load().config
+}
+
+func (s *Settings) Auth() config.AuthConfig {
+	return s.current.Load().auth
+}
+
+func (s *Settings) RateLimits() config.RateLimitConfig {
+	return s.current.Load().rateLimits
+}
+
+// LogLevel returns the level currently in effect.
+func (s *Settings) LogLevel() slog.Level {
+	return s.level.Level()
+}
+
+// SetLogLevel changes the level until the next reload, which restores the
+// configured one.
+func (s *Settings) SetLogLevel(level slog.Level) {
+	s.level.Set(level)
+}
+
+// Feature reports whether the named feature is on. Unknown features are off.
+func (s *Settings) Feature(name string) bool {
+	return s.current.Load().features[name]
+}
+
+// Level returns the configured log level, falling back to debug outside prod.
+func Level(cfg *config.Config) slog.Level {
+	switch cfg.Log.Level {
+	case "debug":
+		return slog.LevelDebug
+	case "info":
+		return slog.LevelInfo
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+
+	if cfg.Env == "prod" {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
@@ -0,0 +1,105 @@
+package sl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_Size(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.log")
+
+	f, err := NewRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := f.Write([]byte("12345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "order-*.log"))
+	if len(backups) != 2 {
+		t.Errorf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "12345678\n" {
+		t.Errorf("current file holds %q, want the last write only", b)
+	}
+}
+
+func TestRotatingFile_Age(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.log")
+
+	f, err := NewRotatingFile(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	start := f.opened
+	f.now = func() time.Time { return start.Add(30 * time.Minute) }
+	_, _ = f.Write([]byte("a\n"))
+	_, _ = f.Write([]byte("b\n"))
+
+	f.now = func() time.Time { return start.Add(2 * time.Hour) }
+	_, _ = f.Write([]byte("c\n"))
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "order-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+	if b, _ := os.ReadFile(backups[0]); string(b) != "a\nb\n" {
+		t.Errorf("backup holds %q", b)
+	}
+}
+
+func TestRotatingFile_RenameFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.log")
+
+	f, err := NewRotatingFile(path, 4, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	// A non-empty directory where the backup should go makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(f.backupName(now), "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte("abc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.Write([]byte("def\n")); n != 4 || err == nil {
+		t.Errorf("got %d, %v, want the write and the rename error", n, err)
+	}
+	if n, err := f.Write([]byte("ghi\n")); n != 4 || err == nil {
+		t.Errorf("got %d, %v", n, err)
+	}
+
+	if b, _ := os.ReadFile(path); string(b) != "abc\ndef\nghi\n" {
+		t.Errorf("current file holds %q", b)
+	}
+
+	f.Close()
+	if _, err := f.Write([]byte("jkl\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got %v after Close", err)
+	}
+}
@@ -0,0 +1,71 @@
+package sl
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SamplingHandler thins out repetitive debug records. Within each tick the
+// first records with a given message pass, then only every thereafter-th
+// one; zero thereafter drops the rest. Info and above always pass.
+type SamplingHandler struct {
+	slog.Handler
+	sampler *sampler
+}
+
+type sampler struct {
+	first      int
+	thereafter int
+	tick       time.Duration
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func NewSamplingHandler(h slog.Handler, first, thereafter int, tick time.Duration) *SamplingHandler {
+	return &SamplingHandler{
+		Handler: h,
+		sampler: &sampler{
+			first:      first,
+			thereafter: thereafter,
+			tick:       tick,
+			counts:     make(map[string]int),
+		},
+	}
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo && !h.sampler.allow(r.Message, r.Time) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithGroup(name), sampler: h.sampler}
+}
+
+func (s *sampler) allow(msg string, t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Sub(s.window) >= s.tick {
+		s.window = t
+		clear(s.counts)
+	}
+
+	s.counts[msg]++
+	n := s.counts[msg]
+	if n <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
@@ -0,0 +1,36 @@
+package sl_test
+
+import (
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	h, w := newHandler(&slog.HandlerOptions{Level: slog.LevelDebug})
+	log := slog.New(sl.NewSamplingHandler(h, 2, 3, time.Hour))
+
+	for i := 0; i < 11; i++ {
+		log.Debug("tick")
+		log.Info("always")
+	}
+	log.With("a", 1).Debug("other")
+
+	var debug, info int
+	for _, r := range w.records {
+		switch parse(t, r)[slog.MessageKey] {
+		case "tick":
+			debug++
+		case "always":
+			info++
+		}
+	}
+	// 2 in full, then every 3rd of the remaining 9.
+	if debug != 5 || info != 11 {
+		t.Errorf("got %d debug and %d info records, want 5 and 11", debug, info)
+	}
+	if len(w.records) != debug+info+1 {
+		t.Errorf("a different message must not be sampled with tick")
+	}
+}
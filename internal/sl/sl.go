@@ -3,10 +3,15 @@ package sl
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/fatih/color"
 	"io"
-	stdLog "log"
 	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 func Err(err error) slog.Attr {
@@ -20,85 +25,236 @@ type PrettyHandlerOptions struct {
 	SlogOpts *slog.HandlerOptions
 }
 
+// PrettyHandler prints a colored "[time] LEVEL: message" line followed by the
+// attributes as indented JSON. Groups become nested objects and keys are
+// printed in sorted order.
 type PrettyHandler struct {
-	opts PrettyHandlerOptions
-	slog.Handler
-	l     *stdLog.Logger
+	opts slog.HandlerOptions
+	out  io.Writer
+	mu   *sync.Mutex
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is either a group opened by WithGroup or attributes added by
+// WithAttrs, kept in call order so attributes land in the right group.
+type groupOrAttrs struct {
+	group string
 	attrs []slog.Attr
 }
 
+// fields is a JSON object being built for one record.
+type fields map[string]interface{}
+
 func (opts PrettyHandlerOptions) NewPrettyHandler(
 	out io.Writer,
 ) *PrettyHandler {
 	h := &PrettyHandler{
-		Handler: slog.NewJSONHandler(out, opts.SlogOpts),
-		l:       stdLog.New(out, "", 0),
+		out: out,
+		mu:  &sync.Mutex{},
+	}
+	if opts.SlogOpts != nil {
+		h.opts = *opts.SlogOpts
 	}
 
 	return h
 }
 
+func (h *PrettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+
+	return level >= minLevel
+}
+
 func (h *PrettyHandler) Handle(_ context.Context, r slog.Record) error {
-	level := r.Level.String() + ":"
+	root := fields{}
 
-	switch r.Level {
-	case slog.LevelDebug:
-		level = color.MagentaString(level)
-	case slog.LevelInfo:
-		level = color.BlueString(level)
-	case slog.LevelWarn:
-		level = color.YellowString(level)
-	case slog.LevelError:
-		level = color.RedString(level)
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		h.add(root, nil, slog.Any(slog.SourceKey, &slog.Source{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		}))
 	}
 
-	fields := make(map[string]interface{}, r.NumAttrs())
+	current := root
+	var groups []string
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			current = current.group(goa.group)
+			groups = append(groups, goa.group)
+			continue
+		}
+		for _, a := range goa.attrs {
+			h.add(current, groups, a)
+		}
+	}
 
 	r.Attrs(func(a slog.Attr) bool {
-		fields[a.Key] = a.Value.Any()
-
+		h.add(current, groups, a)
 		return true
 	})
 
-	for _, a := range h.attrs {
-		fields[a.Key] = a.Value.Any()
-	}
-
-	var b []byte
-	var err error
+	root.prune()
 
-	if len(fields) > 0 {
-		b, err = json.MarshalIndent(fields, "", "  ")
+	line := strings.Join(h.header(r), " ")
+	if len(root) > 0 {
+		b, err := json.MarshalIndent(root, "", "  ")
 		if err != nil {
 			return err
 		}
+		line += " " + color.WhiteString(string(b))
 	}
 
-	timeStr := r.Time.Format("[15:05:05.000]")
-	msg := color.CyanString(r.Message)
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	h.l.Println(
-		timeStr,
-		level,
-		msg,
-		color.WhiteString(string(b)),
-	)
+	_, err := io.WriteString(h.out, line+"\n")
 
-	return nil
+	return err
 }
 
 func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &PrettyHandler{
-		Handler: h.Handler,
-		l:       h.l,
-		attrs:   attrs,
+	if len(attrs) == 0 {
+		return h
 	}
+	return h.with(groupOrAttrs{attrs: attrs})
 }
 
 func (h *PrettyHandler) WithGroup(name string) slog.Handler {
-	// TODO: implement
-	return &PrettyHandler{
-		Handler: h.Handler.WithGroup(name),
-		l:       h.l,
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *PrettyHandler) with(goa groupOrAttrs) *PrettyHandler {
+	h2 := *h
+	h2.goas = make([]groupOrAttrs, len(h.goas), len(h.goas)+1)
+	copy(h2.goas, h.goas)
+	h2.goas = append(h2.goas, goa)
+
+	return &h2
+}
+
+// header renders time, level and message, each passed through ReplaceAttr.
+func (h *PrettyHandler) header(r slog.Record) []string {
+	var parts []string
+
+	if !r.Time.IsZero() {
+		if a := h.builtin(slog.Time(slog.TimeKey, r.Time)); a.Key != "" {
+			if a.Value.Kind() == slog.KindTime {
+				parts = append(parts, a.Value.Time().Format("[15:04:05.000]"))
+			} else {
+				parts = append(parts, "["+a.Value.String()+"]")
+			}
+		}
+	}
+
+	if a := h.builtin(slog.Any(slog.LevelKey, r.Level)); a.Key != "" {
+		level := a.Value.String() + ":"
+		switch {
+		case r.Level >= slog.LevelError:
+			level = color.RedString(level)
+		case r.Level >= slog.LevelWarn:
+			level = color.YellowString(level)
+		case r.Level >= slog.LevelInfo:
+			level = color.BlueString(level)
+		default:
+			level = color.MagentaString(level)
+		}
+		parts = append(parts, level)
+	}
+
+	if a := h.builtin(slog.String(slog.MessageKey, r.Message)); a.Key != "" {
+		parts = append(parts, color.CyanString(a.Value.String()))
+	}
+
+	return parts
+}
+
+func (h *PrettyHandler) builtin(a slog.Attr) slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return a
+	}
+	a = h.opts.ReplaceAttr(nil, a)
+	a.Value = a.Value.Resolve()
+
+	return a
+}
+
+// add puts a into dst. groups are the names of the groups dst is nested in,
+// as passed to ReplaceAttr.
+func (h *PrettyHandler) add(dst fields, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key != "" {
+			dst = dst.group(a.Key)
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range attrs {
+			h.add(dst, groups, ga)
+		}
+		return
+	}
+
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Key == "" {
+		return
+	}
+
+	dst[a.Key] = value(a.Value)
+}
+
+func value(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case *slog.Source:
+			return fmt.Sprintf("%s:%d", filepath.Join(filepath.Base(filepath.Dir(x.File)), filepath.Base(x.File)), x.Line)
+		case json.Marshaler:
+			return x
+		case error:
+			return x.Error()
+		}
+	}
+
+	return v.Any()
+}
+
+func (f fields) group(name string) fields {
+	if g, ok := f[name].(fields); ok {
+		return g
+	}
+	g := fields{}
+	f[name] = g
+
+	return g
+}
+
+// prune drops groups that ended up without attributes.
+func (f fields) prune() {
+	for k, v := range f {
+		if g, ok := v.(fields); ok {
+			g.prune()
+			if len(g) == 0 {
+				delete(f, k)
+			}
+		}
 	}
 }
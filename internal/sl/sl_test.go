@@ -0,0 +1,183 @@
+package sl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/bxiit/order-service-pet-store/internal/sl"
+	"github.com/fatih/color"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func init() {
+	color.NoColor = true
+}
+
+// recordWriter keeps every Write separately; PrettyHandler writes one record
+// per call.
+type recordWriter struct {
+	records []string
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.records = append(w.records, string(p))
+	return len(p), nil
+}
+
+var recordPattern = regexp.MustCompile(`(?s)^(?:\[([^\]]*)\] )?(\S+): (.*?)(?: (\{.*\}))?\n$`)
+
+// parse turns a printed record back into the map slogtest expects.
+func parse(t *testing.T, record string) map[string]any {
+	t.Helper()
+
+	m := recordPattern.FindStringSubmatch(record)
+	if m == nil {
+		t.Fatalf("malformed record %q", record)
+	}
+
+	fields := map[string]any{}
+	if m[4] != "" {
+		if err := json.Unmarshal([]byte(m[4]), &fields); err != nil {
+			t.Fatalf("record %q: %v", record, err)
+		}
+	}
+	if m[1] != "" {
+		fields[slog.TimeKey] = m[1]
+	}
+	fields[slog.LevelKey] = m[2]
+	fields[slog.MessageKey] = m[3]
+
+	return fields
+}
+
+func newHandler(opts *slog.HandlerOptions) (*sl.PrettyHandler, *recordWriter) {
+	w := &recordWriter{}
+	return sl.PrettyHandlerOptions{SlogOpts: opts}.NewPrettyHandler(w), w
+}
+
+func TestPrettyHandler_slogtest(t *testing.T) {
+	h, w := newHandler(nil)
+
+	err := slogtest.TestHandler(h, func() []map[string]any {
+		results := make([]map[string]any, 0, len(w.records))
+		for _, r := range w.records {
+			results = append(results, parse(t, r))
+		}
+		return results
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrettyHandler_Format(t *testing.T) {
+	h, w := newHandler(nil)
+
+	r := slog.NewRecord(time.Date(2024, 5, 1, 13, 7, 42, 123e6, time.UTC), slog.LevelWarn, "disk almost full", 0)
+	r.AddAttrs(slog.Int("free", 3), slog.String("disk", "sda"))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[13:07:42.123] WARN: disk almost full {\n  \"disk\": \"sda\",\n  \"free\": 3\n}\n"
+	if w.records[0] != want {
+		t.Errorf("got %q, want %q", w.records[0], want)
+	}
+}
+
+func TestPrettyHandler_WithAttrsAccumulates(t *testing.T) {
+	h, w := newHandler(nil)
+
+	log := slog.New(h).With("a", 1).With("b", 2).WithGroup("g").With("c", 3)
+	log.Info("msg", "d", 4)
+
+	got := parse(t, w.records[0])
+	if got["a"] != 1.0 || got["b"] != 2.0 {
+		t.Errorf("top-level attrs lost: %v", got)
+	}
+	g, _ := got["g"].(map[string]any)
+	if g["c"] != 3.0 || g["d"] != 4.0 {
+		t.Errorf("group attrs lost: %v", got)
+	}
+}
+
+func TestPrettyHandler_Level(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+	h, w := newHandler(&slog.HandlerOptions{Level: level})
+
+	log := slog.New(h)
+	log.Info("hidden")
+	log.Warn("shown")
+	level.Set(slog.LevelDebug)
+	log.Debug("shown too")
+
+	if len(w.records) != 2 {
+		t.Fatalf("got %d records, want 2: %q", len(w.records), w.records)
+	}
+}
+
+func TestPrettyHandler_ReplaceAttr(t *testing.T) {
+	var seen [][]string
+	h, w := newHandler(&slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey, "secret":
+				return slog.Attr{}
+			case "email":
+				seen = append(seen, groups)
+				return slog.String(a.Key, "***")
+			}
+			return a
+		},
+	})
+
+	slog.New(h).WithGroup("user").Info("login", "email", "a@b.c", "secret", "x")
+
+	record := w.records[0]
+	if strings.HasPrefix(record, "[") {
+		t.Errorf("time not removed: %q", record)
+	}
+	got := parse(t, record)
+	user, _ := got["user"].(map[string]any)
+	if user["email"] != "***" || user["secret"] != nil {
+		t.Errorf("attrs not replaced: %v", got)
+	}
+	if len(seen) != 1 || len(seen[0]) != 1 || seen[0][0] != "user" {
+		t.Errorf("ReplaceAttr got groups %v, want [[user]]", seen)
+	}
+}
+
+func TestPrettyHandler_AddSource(t *testing.T) {
+	h, w := newHandler(&slog.HandlerOptions{AddSource: true})
+
+	slog.New(h).Info("msg")
+
+	source, _ := parse(t, w.records[0])[slog.SourceKey].(string)
+	if !strings.HasPrefix(source, "sl/sl_test.go:") {
+		t.Errorf("got source %q, want sl/sl_test.go:<line>", source)
+	}
+}
+
+func TestPrettyHandler_Deterministic(t *testing.T) {
+	var out bytes.Buffer
+	h := sl.PrettyHandlerOptions{}.NewPrettyHandler(&out)
+
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0)
+	r.AddAttrs(slog.Int("z", 1), slog.Int("a", 2), slog.Int("m", 3))
+
+	_ = h.Handle(context.Background(), r)
+	first := out.String()
+	for i := 0; i < 20; i++ {
+		out.Reset()
+		_ = h.Handle(context.Background(), r)
+		if out.String() != first {
+			t.Fatalf("output changed between runs:\n%s\n%s", first, out.String())
+		}
+	}
+}
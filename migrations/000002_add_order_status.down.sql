@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS order_service.orders_user_id_idx;
+
+ALTER TABLE order_service.orders
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS status;
\ No newline at end of file
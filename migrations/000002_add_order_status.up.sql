@@ -0,0 +1,5 @@
+ALTER TABLE order_service.orders
+    ADD COLUMN status     TEXT        NOT NULL DEFAULT 'created',
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON order_service.orders (user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS order_service.orders_created_at_idx;
+
+DROP INDEX IF EXISTS catalogue.item_info_search_vector_idx;
+
+ALTER TABLE catalogue.item_info
+    DROP COLUMN IF EXISTS search_vector;
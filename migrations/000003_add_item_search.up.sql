@@ -0,0 +1,13 @@
+-- Full-text search over the items that orders point at. The column lives in
+-- the catalogue schema because that is where the text is; the catalogue
+-- service never writes it, Postgres keeps it up to date.
+ALTER TABLE catalogue.item_info
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+            setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS item_info_search_vector_idx ON catalogue.item_info USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON order_service.orders (created_at);
@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW IF EXISTS order_service.daily_sales;
@@ -0,0 +1,16 @@
+-- Pre-aggregated sales per UTC day, item and user for the reporting RPCs.
+-- Refreshed by the service when reports.use_views is on.
+CREATE MATERIALIZED VIEW IF NOT EXISTS order_service.daily_sales AS
+SELECT (o.created_at AT TIME ZONE 'UTC')::date AS day,
+       o.item_id,
+       o.user_id,
+       count(*)     AS orders,
+       sum(i.price) AS revenue
+FROM order_service.orders o
+INNER JOIN catalogue.item_info i
+ON o.item_id = i.id
+WHERE o.status <> 'cancelled'
+GROUP BY 1, 2, 3;
+
+-- Required by REFRESH MATERIALIZED VIEW CONCURRENTLY.
+CREATE UNIQUE INDEX IF NOT EXISTS daily_sales_key ON order_service.daily_sales (day, item_id, user_id);
@@ -0,0 +1,7 @@
+-- Orders each caller created per UTC day, for the daily order quota.
+CREATE TABLE IF NOT EXISTS order_service.order_quota (
+    user_id BIGINT  NOT NULL,
+    day     DATE    NOT NULL,
+    orders  INTEGER NOT NULL,
+    PRIMARY KEY (user_id, day)
+);